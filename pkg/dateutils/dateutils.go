@@ -238,3 +238,49 @@ func Pretify(t time.Time) string {
 
 	return fmt.Sprintf("%d:%d %d-%s-%d", t.Hour(), t.Minute(), t.Day(), monthStr, t.Year())
 }
+
+var ISO8601_LAYOUTS = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	time.DateOnly,
+}
+
+// Format example: "2024-10-12T10:01:00+03:00", "2024-10-12T10:01:00.000Z", "2024-10-12"
+// The date without the zone is in the local time.
+func ParseISO8601(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	for _, layout := range ISO8601_LAYOUTS {
+		if t, err := time.ParseInLocation(layout, str, time.Now().Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrUnsupportedDateFormat
+}
+
+// Layouts of the RSS dates. Feeds often break the RFC 822, like the single digit day or the missing weekday.
+var RFC822_LAYOUTS = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// Format example: "Sat, 12 Oct 2024 10:01:00 +0300", "Sat, 12 Oct 2024 10:01:00 GMT"
+// The ISO 8601 dates are also accepted, some feeds use them.
+func ParseRFC822(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	for _, layout := range RFC822_LAYOUTS {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, nil
+		}
+	}
+	return ParseISO8601(str)
+}
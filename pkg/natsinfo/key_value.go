@@ -13,3 +13,22 @@ var (
 		TTL:    time.Minute * 2,
 	}
 )
+
+var (
+	TEMPLATES_BUCKET_NAME      = "templates"
+	TEMPLATES_KEY_VALUE_CONFIG = nats.KeyValueConfig{
+		Bucket:      TEMPLATES_BUCKET_NAME,
+		Description: "News feed templates of the worker",
+		History:     5,
+	}
+)
+
+var (
+	SEEN_URLS_BUCKET_NAME      = "seen_urls"
+	SEEN_URLS_KEY_VALUE_CONFIG = nats.KeyValueConfig{
+		Bucket:      SEEN_URLS_BUCKET_NAME,
+		Description: "Article urls which are pulled by the workers",
+		// The sources don't link the articles which are older than that
+		TTL: time.Hour * 24 * 30,
+	}
+)
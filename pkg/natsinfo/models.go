@@ -8,6 +8,8 @@ import (
 )
 
 type Article struct {
+	// Url of the article page
+	URL           string
 	Title         string
 	Preface       string
 	Content       string
@@ -15,10 +17,15 @@ type Article struct {
 	ViewersCount  int
 	MainImage     string
 	ContentImages []string
+	Authors       []string
+	Section       string
 	Origin        string
+	// Encoding of the source page before transcoding to the utf-8
+	Charset string
 }
 
 type articleDTO struct {
+	URL           string   `json:"url,omitempty"`
 	Title         string   `json:"title"`
 	Preface       string   `json:"preface"`
 	Content       string   `json:"content"`
@@ -26,12 +33,16 @@ type articleDTO struct {
 	ViewersCount  int      `json:"viewers_count"`
 	MainImage     string   `json:"main_image"`
 	ContentImages []string `json:"content_images,omitempty"`
+	Authors       []string `json:"authors,omitempty"`
+	Section       string   `json:"section,omitempty"`
 	Origin        string   `json:"origin"`
+	Charset       string   `json:"charset,omitempty"`
 }
 
 func (a *Article) Marshal() ([]byte, error) {
 	return json.Marshal(
 		&articleDTO{
+			URL:           a.URL,
 			Title:         a.Title,
 			Preface:       a.Preface,
 			Content:       a.Content,
@@ -39,7 +50,10 @@ func (a *Article) Marshal() ([]byte, error) {
 			ViewersCount:  a.ViewersCount,
 			MainImage:     a.MainImage,
 			ContentImages: a.ContentImages,
+			Authors:       a.Authors,
+			Section:       a.Section,
 			Origin:        a.Origin,
+			Charset:       a.Charset,
 		},
 	)
 }
@@ -51,13 +65,17 @@ func (a *Article) Unmarshal(data []byte) error {
 		return err
 	}
 
+	a.URL = dto.URL
 	a.Title = dto.Title
 	a.Preface = dto.Preface
 	a.Content = dto.Content
 	a.ViewersCount = dto.ViewersCount
 	a.MainImage = dto.MainImage
 	a.ContentImages = dto.ContentImages
+	a.Authors = dto.Authors
+	a.Section = dto.Section
 	a.Origin = dto.Origin
+	a.Charset = dto.Charset
 
 	time, err := dateutils.ParseString(dto.PublishedAt)
 	if err != nil {
@@ -3,8 +3,10 @@ module github.com/romashorodok/news-tracker/worker
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/nats-io/nats.go v1.32.0
 	go.uber.org/fx v1.20.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
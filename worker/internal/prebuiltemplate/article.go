@@ -1,13 +1,17 @@
 package prebuiltemplate
 
 import (
+	"log"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/romashorodok/news-tracker/pkg/dateutils"
 	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/jsonld"
+	"github.com/romashorodok/news-tracker/worker/pkg/metadata"
 	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/xpath"
 )
 
 const (
@@ -18,12 +22,98 @@ const (
 	FIELD_TYPE_INFO           = "info"
 	FIELD_TYPE_MAIN_IMAGE     = "main_image"
 	FIELD_TYPE_CONTENT_IMAGES = "content_images"
+	FIELD_TYPE_AUTHOR         = "author"
+	FIELD_TYPE_SECTION        = "section"
 )
 
 type Field struct {
-	Type             string   `json:"type"`
-	ClassSelector    string   `json:"class_selector"`
+	Type          string `json:"type"`
+	ClassSelector string `json:"class_selector"`
+	// Takes precedence over the ClassSelector
+	CssSelector string `json:"css_selector"`
+	// Takes precedence over the CssSelector and ClassSelector
+	XPath            string   `json:"xpath"`
 	IgnoredSentences []string `json:"ignored_sentences"`
+
+	// Where the value is taken from. The selectors of the field by default.
+	// Example: `jsonld` or `jsonld:author.name` to take it from the structured data of the page,
+	// `meta` or `meta:og:image` to take it from the `<meta>` of the page
+	Source string `json:"source"`
+	// Sources which are tried one by one while the field is still empty
+	Fallback []string `json:"fallback"`
+	// Applied in order to the values of each source
+	Transforms []Transform `json:"transforms"`
+}
+
+func (f Field) hasSelector() bool {
+	return f.XPath != "" || f.CssSelector != "" || f.ClassSelector != ""
+}
+
+// Source of the field followed by its fallback sources
+func (f Field) sources() []string {
+	source := f.Source
+	if source == "" {
+		source = FIELD_SOURCE_SELECTOR
+	}
+	return append([]string{source}, f.Fallback...)
+}
+
+// The fields of the template select the nodes of each page by the same xpath, so it's compiled once
+var compiledXPaths sync.Map
+
+func compileXPath(source string) (*xpath.Expr, error) {
+	if compiled, ok := compiledXPaths.Load(source); ok {
+		return compiled.(*xpath.Expr), nil
+	}
+
+	compiled, err := xpath.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	compiledXPaths.Store(source, compiled)
+	return compiled, nil
+}
+
+// Nodes of the document selected by the field.
+// Like the streaming selectors only the outermost nodes are selected, the nested matches are the part of them.
+func fieldNodes(doc *parser.Document, field Field) ([]*parser.Node, error) {
+	var nodes []*parser.Node
+	switch {
+	case !field.hasSelector():
+		return nil, nil
+	case field.XPath != "":
+		expr, err := compileXPath(field.XPath)
+		if err != nil {
+			return nil, err
+		}
+		nodes = expr.Select(doc.Root)
+	case field.CssSelector != "":
+		var err error
+		if nodes, err = doc.QueryAll(field.CssSelector); err != nil {
+			return nil, err
+		}
+	default:
+		nodes = doc.Root.FindAll(parser.ByClass(field.ClassSelector))
+	}
+
+	var outermost []*parser.Node
+	for _, node := range nodes {
+		// Nodes are in document order, so the nested node always follow its outermost ancestor
+		if len(outermost) > 0 && isDescendantOf(node, outermost[len(outermost)-1]) {
+			continue
+		}
+		outermost = append(outermost, node)
+	}
+	return outermost, nil
+}
+
+func isDescendantOf(node, ancestor *parser.Node) bool {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
 }
 
 type ArticleExtractorConfig struct {
@@ -33,42 +123,40 @@ type ArticleExtractorConfig struct {
 type ArticlePageExtractor struct {
 	article natsinfo.Article
 	config  NewsFeedConfig
+
+	// JSON-LD article of the page, it's decoded on first use
+	structured       jsonld.Object
+	structuredParsed bool
+	// `<meta>` of the page, it's collected on first use
+	metadata metadata.Metadata
 }
 
 func (n *ArticlePageExtractor) OnMainImage(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		for node := node; node != nil; node = node.Next {
-			if node.Name == "img" {
-				if node.Tag.Attr == nil {
-					continue
-				}
-				n.article.MainImage = n.config.ArticlePrefixURL + node.Tag.Attr["src"]
-				break
-			}
+		img := node.Find(func(node *parser.Node) bool {
+			return parser.ByName("img")(node) && node.Attr("src") != ""
+		})
+		if img == nil {
+			return
 		}
+		n.article.MainImage = n.absoluteURL(img.Attr("src"))
 	}
 }
 
 func (n *ArticlePageExtractor) OnContentImages(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		for node := node; node != nil; node = node.Next {
-			if node.Name == "img" {
-				if node.Tag.Attr == nil {
-					continue
-				}
-				img := n.config.ArticlePrefixURL + node.Tag.Attr["src"]
-				n.article.ContentImages = append(n.article.ContentImages, img)
+		for _, img := range node.FindAll(parser.ByName("img")) {
+			if img.Attr("src") == "" {
+				continue
 			}
+			n.article.ContentImages = append(n.article.ContentImages, n.absoluteURL(img.Attr("src")))
 		}
 	}
 }
 
 func (n *ArticlePageExtractor) OnContent(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		var content string
-		for node := node; node != nil; node = node.Next {
-			content += node.Content
-		}
+		content := node.Text()
 		for _, sentence := range field.IgnoredSentences {
 			content = strings.Replace(content, sentence, "", -1)
 		}
@@ -78,21 +166,20 @@ func (n *ArticlePageExtractor) OnContent(field Field) func(*parser.Node) {
 
 func (n *ArticlePageExtractor) OnPreface(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		n.article.Preface = node.Next.Content
+		n.article.Preface = node.Text()
 	}
 }
 
 func (n *ArticlePageExtractor) OnTitle(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		n.article.Title = node.Next.Content
+		n.article.Title = node.Text()
 	}
 }
 
 func (n *ArticlePageExtractor) OnPublishDate(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		date, err := dateutils.ParseDateUA(node.Next.Content)
+		date, err := dateutils.ParseDateUA(node.Text())
 		if err != nil {
-			n.article.PublishedAt = time.Now()
 			return
 		}
 		n.article.PublishedAt = date
@@ -101,25 +188,115 @@ func (n *ArticlePageExtractor) OnPublishDate(field Field) func(*parser.Node) {
 
 const VIEWERS_COUNT_SELECTOR = "ServicePeopleItem__icon ServicePeopleItem__icon_look"
 
+// The viewers count is the text next to the viewers icon
+//
+// Example: <span class="ServicePeopleItem__icon ServicePeopleItem__icon_look"><svg>...</svg></span><span>1234</span>
 func (n *ArticlePageExtractor) OnInfo(field Field) func(*parser.Node) {
 	return func(node *parser.Node) {
-		for node := node; node != nil; node = node.Next {
-			if node.Tag.Attr == nil {
+		icon := node.Find(parser.ByClass(VIEWERS_COUNT_SELECTOR))
+		if icon == nil {
+			return
+		}
+
+		for sibling := icon.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+			text := strings.TrimSpace(sibling.Text())
+			if text == "" {
 				continue
 			}
 
-			if parser.ContainsClass(node.Tag.Attr["class"], []string{VIEWERS_COUNT_SELECTOR}) {
-				viewersCount, err := strconv.Atoi(node.Next.Next.Next.Content)
-				if err != nil {
-					n.article.ViewersCount = 0
-					return
-				}
-
-				n.article.ViewersCount = viewersCount
+			viewersCount, err := strconv.Atoi(text)
+			if err != nil {
+				n.article.ViewersCount = 0
 				return
 			}
+
+			n.article.ViewersCount = viewersCount
+			return
+		}
+	}
+}
+
+// Absolute url is kept as is, the relative one is prefixed by the ArticlePrefixURL
+func (n *ArticlePageExtractor) absoluteURL(ref string) string {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "//") {
+		return ref
+	}
+	return n.config.ArticlePrefixURL + ref
+}
+
+// When the main image selector found nothing the image from the page preview is used
+func (n *ArticlePageExtractor) fallbackMainImage(doc *parser.Document) {
+	if n.article.MainImage != "" {
+		return
+	}
+	for _, key := range META_PROPERTIES[FIELD_TYPE_MAIN_IMAGE] {
+		if image := n.pageMetadata(doc).Get(key); image != "" {
+			n.article.MainImage = n.absoluteURL(image)
+			return
+		}
+	}
+}
+
+func (n *ArticlePageExtractor) OnAuthor(field Field) func(*parser.Node) {
+	return func(node *parser.Node) {
+		if author := strings.TrimSpace(node.Text()); author != "" {
+			n.article.Authors = append(n.article.Authors, author)
+		}
+	}
+}
+
+func (n *ArticlePageExtractor) OnSection(field Field) func(*parser.Node) {
+	return func(node *parser.Node) {
+		n.article.Section = strings.TrimSpace(node.Text())
+	}
+}
+
+// Handler of the field nodes or nil when the field type is unknown
+func (n *ArticlePageExtractor) fieldHandler(field Field) func(*parser.Node) {
+	switch field.Type {
+	case FIELD_TYPE_TITLE:
+		return n.OnTitle(field)
+	case FIELD_TYPE_CONTENT:
+		return n.OnContent(field)
+	case FIELD_TYPE_PREFACE:
+		return n.OnPreface(field)
+	case FIELD_TYPE_PUBLISHED_AT:
+		return n.OnPublishDate(field)
+	case FIELD_TYPE_INFO:
+		return n.OnInfo(field)
+	case FIELD_TYPE_MAIN_IMAGE:
+		return n.OnMainImage(field)
+	case FIELD_TYPE_CONTENT_IMAGES:
+		return n.OnContentImages(field)
+	case FIELD_TYPE_AUTHOR:
+		return n.OnAuthor(field)
+	case FIELD_TYPE_SECTION:
+		return n.OnSection(field)
+	}
+	return nil
+}
+
+// Extract the article fields from the whole page. The fields which are not found are empty.
+func (n *ArticlePageExtractor) Extract(doc *parser.Document) natsinfo.Article {
+	for _, field := range n.config.ArticleConfig.Fields {
+		onField := n.fieldHandler(field)
+		if onField == nil {
+			continue
+		}
+
+		for _, source := range field.sources() {
+			if err := n.extractFromSource(doc, field, source, onField); err != nil {
+				log.Printf("Unable extract %s field from %s. Err: %s", field.Type, source, err)
+				continue
+			}
+			if !n.isFieldEmpty(field.Type) {
+				break
+			}
 		}
 	}
+
+	n.fallbackMainImage(doc)
+	return n.article
 }
 
 func NewArticlePageExtractor(config NewsFeedConfig) *ArticlePageExtractor {
@@ -0,0 +1,247 @@
+package prebuiltemplate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+const ARTICLE_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+	<meta property="og:title" content="Meta title">
+	<meta property="og:description" content="Meta preface">
+	<meta property="og:image" content="/images/og.jpg">
+	<meta property="article:section" content="Meta section">
+	<script type="application/ld+json">
+	{
+		"@context": "https://schema.org",
+		"@type": "NewsArticle",
+		"headline": "JSON-LD title",
+		"author": {"@type": "Person", "name": "Ivan Petrenko"}
+	}
+	</script>
+</head>
+<body>
+	<h1 class="title">Css title</h1>
+	<div class="headline"><span>XPath title</span></div>
+	<p class="lead">   </p>
+	<div class="body">
+		<p>First   paragraph.</p>
+		<p>Subscribe to our channel</p>
+	</div>
+	<div class="gallery"><img src="/images/1.jpg"><img src="https://cdn.example.com/2.jpg"></div>
+	<span class="views">Переглядів: 1 234</span>
+	<span class="tags">Politics, Economy ,</span>
+</body>
+</html>`
+
+func extractArticle(t *testing.T, fields ...Field) natsinfo.Article {
+	t.Helper()
+
+	doc, err := parser.ParseDocument(strings.NewReader(ARTICLE_PAGE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewsFeedConfig{ArticlePrefixURL: "https://news.example.com"}
+	config.ArticleConfig.Fields = fields
+	return NewArticlePageExtractor(config).Extract(doc)
+}
+
+func TestExtractFallbackOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		field Field
+		want  natsinfo.Article
+	}{
+		{
+			name:  "xpath takes precedence over css",
+			field: Field{Type: FIELD_TYPE_TITLE, XPath: "//div[@class='headline']/span", CssSelector: "h1.title"},
+			want:  natsinfo.Article{Title: "XPath title"},
+		},
+		{
+			name:  "css takes precedence over class",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", ClassSelector: "headline"},
+			want:  natsinfo.Article{Title: "Css title"},
+		},
+		{
+			name:  "selector is tried before fallback",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", Fallback: []string{FIELD_SOURCE_JSONLD, FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Title: "Css title"},
+		},
+		{
+			name:  "missing selector falls back to json-ld",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h2.missing", Fallback: []string{FIELD_SOURCE_JSONLD, FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Title: "JSON-LD title"},
+		},
+		{
+			name:  "missing xpath falls back to json-ld",
+			field: Field{Type: FIELD_TYPE_TITLE, XPath: "//h2", Fallback: []string{FIELD_SOURCE_JSONLD}},
+			want:  natsinfo.Article{Title: "JSON-LD title"},
+		},
+		{
+			name:  "json-ld is tried before meta",
+			field: Field{Type: FIELD_TYPE_TITLE, Source: FIELD_SOURCE_JSONLD, Fallback: []string{FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Title: "JSON-LD title"},
+		},
+		{
+			name:  "empty selector and missing json-ld property fall back to meta",
+			field: Field{Type: FIELD_TYPE_PREFACE, CssSelector: "p.lead", Transforms: []Transform{{Type: TRANSFORM_TRIM}}, Fallback: []string{FIELD_SOURCE_JSONLD, FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Preface: "Meta preface"},
+		},
+		{
+			name:  "missing json-ld property falls back to meta",
+			field: Field{Type: FIELD_TYPE_SECTION, Source: FIELD_SOURCE_JSONLD, Fallback: []string{FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Section: "Meta section"},
+		},
+		{
+			name:  "unknown source is skipped",
+			field: Field{Type: FIELD_TYPE_TITLE, Source: "microdata", Fallback: []string{FIELD_SOURCE_META}},
+			want:  natsinfo.Article{Title: "Meta title"},
+		},
+		{
+			name:  "exact json-ld property",
+			field: Field{Type: FIELD_TYPE_AUTHOR, Source: "jsonld:author.name"},
+			want:  natsinfo.Article{Authors: []string{"Ivan Petrenko"}},
+		},
+		{
+			name:  "exact meta property",
+			field: Field{Type: FIELD_TYPE_TITLE, Source: "meta:og:description"},
+			want:  natsinfo.Article{Title: "Meta preface"},
+		},
+		{
+			name:  "field is empty when no source has it",
+			field: Field{Type: FIELD_TYPE_CONTENT, CssSelector: "article.missing", Fallback: []string{FIELD_SOURCE_JSONLD, FIELD_SOURCE_META}},
+			want:  natsinfo.Article{},
+		},
+		{
+			name:  "field without selector is empty",
+			field: Field{Type: FIELD_TYPE_CONTENT},
+			want:  natsinfo.Article{},
+		},
+		{
+			name:  "content images keep absolute url",
+			field: Field{Type: FIELD_TYPE_CONTENT_IMAGES, CssSelector: "div.gallery"},
+			want:  natsinfo.Article{ContentImages: []string{"https://news.example.com/images/1.jpg", "https://cdn.example.com/2.jpg"}},
+		},
+		{
+			name:  "main image keeps absolute url",
+			field: Field{Type: FIELD_TYPE_MAIN_IMAGE, CssSelector: "div.gallery img:nth-child(2)"},
+			want:  natsinfo.Article{MainImage: "https://cdn.example.com/2.jpg"},
+		},
+		{
+			name:  "main image falls back to meta",
+			field: Field{Type: FIELD_TYPE_MAIN_IMAGE, CssSelector: "div.missing"},
+			want:  natsinfo.Article{MainImage: "https://news.example.com/images/og.jpg"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := extractArticle(t, test.field)
+			// The main image fallback is always applied, it's tested by its own case
+			if test.field.Type != FIELD_TYPE_MAIN_IMAGE {
+				got.MainImage = ""
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtractTransforms(t *testing.T) {
+	tests := []struct {
+		name  string
+		field Field
+		want  natsinfo.Article
+	}{
+		{
+			name: "strip prefix and to int",
+			field: Field{Type: FIELD_TYPE_INFO, CssSelector: "span.views", Transforms: []Transform{
+				{Type: TRANSFORM_STRIP_PREFIX, Value: "Переглядів:"},
+				{Type: TRANSFORM_TO_INT},
+			}},
+			want: natsinfo.Article{ViewersCount: 1234},
+		},
+		{
+			name: "split drops empty parts",
+			field: Field{Type: FIELD_TYPE_AUTHOR, CssSelector: "span.tags", Transforms: []Transform{
+				{Type: TRANSFORM_SPLIT, Value: ","},
+			}},
+			want: natsinfo.Article{Authors: []string{"Politics", "Economy"}},
+		},
+		{
+			name: "collapse whitespace and trim",
+			field: Field{Type: FIELD_TYPE_CONTENT, CssSelector: "div.body p", Transforms: []Transform{
+				{Type: TRANSFORM_COLLAPSE_WHITESPACE},
+				{Type: TRANSFORM_TRIM},
+			}},
+			want: natsinfo.Article{Content: "First paragraph."},
+		},
+		{
+			name: "regex replace of each image",
+			field: Field{Type: FIELD_TYPE_CONTENT_IMAGES, CssSelector: "div.gallery", Transforms: []Transform{
+				{Type: TRANSFORM_REGEX_REPLACE, Pattern: `\.jpg$`, Replacement: ".webp"},
+			}},
+			want: natsinfo.Article{ContentImages: []string{"https://news.example.com/images/1.webp", "https://cdn.example.com/2.webp"}},
+		},
+		{
+			name: "regex extract group",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", Transforms: []Transform{
+				{Type: TRANSFORM_REGEX_EXTRACT, Pattern: `(\w+) title`, Group: 1},
+				{Type: TRANSFORM_LOWERCASE},
+			}},
+			want: natsinfo.Article{Title: "css"},
+		},
+		{
+			name: "value without match is dropped and falls back",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", Fallback: []string{FIELD_SOURCE_META}, Transforms: []Transform{
+				{Type: TRANSFORM_REGEX_EXTRACT, Pattern: `^Meta .+`},
+			}},
+			want: natsinfo.Article{Title: "Meta title"},
+		},
+		{
+			name: "fallback values are transformed",
+			field: Field{Type: FIELD_TYPE_SECTION, CssSelector: "div.missing", Fallback: []string{FIELD_SOURCE_META}, Transforms: []Transform{
+				{Type: TRANSFORM_STRIP_PREFIX, Value: "Meta "},
+			}},
+			want: natsinfo.Article{Section: "section"},
+		},
+		{
+			name: "to int drops value without digits",
+			field: Field{Type: FIELD_TYPE_INFO, CssSelector: "h1.title", Transforms: []Transform{
+				{Type: TRANSFORM_TO_INT},
+			}},
+			want: natsinfo.Article{},
+		},
+		{
+			name: "unknown transform leaves field empty",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", Fallback: []string{FIELD_SOURCE_META}, Transforms: []Transform{
+				{Type: "reverse"},
+			}},
+			want: natsinfo.Article{},
+		},
+		{
+			name: "invalid pattern leaves field empty",
+			field: Field{Type: FIELD_TYPE_TITLE, CssSelector: "h1.title", Transforms: []Transform{
+				{Type: TRANSFORM_REGEX_REPLACE, Pattern: `(`},
+			}},
+			want: natsinfo.Article{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := extractArticle(t, test.field)
+			got.MainImage = ""
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/charset"
+	"github.com/romashorodok/news-tracker/worker/pkg/feed"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+	"github.com/romashorodok/news-tracker/worker/pkg/sitemap"
+)
+
+var ErrNoArticles = errors.New("no articles found on the news feed page")
+
+// Pages of the dry run. The saved files are read instead of the remote pages when they are set.
+type DryRunOptions struct {
+	// Saved news feed page, feed or sitemap
+	FeedFile string
+	// Saved article page
+	ArticleFile string
+	// Article page which is extracted instead of the first article of the news feed
+	ArticleURL string
+	// Transport of the remote pages, the http.DefaultTransport by default
+	Transport http.RoundTripper
+}
+
+type DryRunResult struct {
+	// Articles found on the news feed page
+	ArticleURLs []string
+	// Article page which is extracted, empty when the feed supplies the article by itself
+	ArticleURL string
+	Article    natsinfo.Article
+	// Configured field types which are not found
+	EmptyFields []string
+}
+
+// Saved page transcoded to the utf-8 like the remote one
+func openSavedPage(path string) (*remotePage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	body, charsetName, err := charset.NewReader(file, "")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &remotePage{Reader: body, body: file, charset: charsetName}, nil
+}
+
+func (n *NewsFeedProcessor) openPage(path, savedPath string) (*remotePage, error) {
+	if savedPath != "" {
+		return openSavedPage(savedPath)
+	}
+	return n.getRemotePage(context.Background(), path)
+}
+
+// Configured field types which are not found, the fields which the template doesn't extract are not reported
+func emptyArticleFields(article natsinfo.Article, fields []Field) []string {
+	var empty []string
+	for _, field := range fields {
+		if slices.Contains(empty, field.Type) {
+			continue
+		}
+		if articleFieldEmpty(article, field.Type) {
+			empty = append(empty, field.Type)
+		}
+	}
+	return empty
+}
+
+// Article of the first feed item, and the urls of all items
+func (n *NewsFeedProcessor) dryRunFeed(options DryRunOptions, base *url.URL) ([]string, *natsinfo.Article, error) {
+	var items []feed.Item
+	var charsetName string
+
+	switch {
+	case n.config.feedType() == feed.TYPE_SITEMAP && options.FeedFile == "":
+		urls, err := n.discoverSitemapURLs(context.Background(), base)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, siteURL := range urls {
+			items = append(items, sitemapItem(siteURL))
+		}
+
+	default:
+		page, err := n.openPage(n.config.NewsFeedURL, options.FeedFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer page.Close()
+		charsetName = page.charset
+
+		if n.config.feedType() == feed.TYPE_SITEMAP {
+			siteSitemap, err := sitemap.Parse(page)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, siteURL := range siteSitemap.URLs {
+				items = append(items, sitemapItem(siteURL))
+			}
+			break
+		}
+
+		newsFeed, err := feed.Parse(page, n.config.feedType())
+		if err != nil {
+			return nil, nil, err
+		}
+		items = newsFeed.Items
+	}
+
+	var urls []string
+	for _, item := range items {
+		urls = append(urls, resolveURL(base, item.URL))
+	}
+	if len(items) == 0 {
+		return urls, nil, nil
+	}
+	article := n.feedItemArticle(items[0], base, charsetName)
+	return urls, &article, nil
+}
+
+// Extract the article of the template without publishing it.
+//
+// The news feed page and one article page are pulled, by default the first article of the news feed.
+func DryRun(config NewsFeedConfig, options DryRunOptions) (*DryRunResult, error) {
+	// The dry run pulls the article page by itself, so it has no crawl scheduler
+	n := newNewsFeedProcessor(config, nil, fetcher.New(fetcher.Config{Transport: options.Transport}), nil)
+	n.origin = originOf(config.NewsFeedURL)
+
+	base, err := url.Parse(config.NewsFeedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{}
+	var feedArticle *natsinfo.Article
+	fetchArticlePage := true
+
+	if config.feedType() == feed.TYPE_HTML {
+		page, err := n.openPage(config.NewsFeedURL, options.FeedFile)
+		if err != nil {
+			return nil, err
+		}
+		defer page.Close()
+
+		newsFeedPage, err := n.scrapeNewsFeedReader(config.NewsFeedURL, page)
+		if err != nil {
+			return nil, err
+		}
+		result.ArticleURLs, _ = n.dueArticleURLs(newsFeedPage.articleURLs)
+	} else {
+		if result.ArticleURLs, feedArticle, err = n.dryRunFeed(options, base); err != nil {
+			return nil, err
+		}
+		fetchArticlePage = config.FetchArticlePage || config.feedType() == feed.TYPE_SITEMAP
+	}
+
+	result.ArticleURL = options.ArticleURL
+	if result.ArticleURL == "" && len(result.ArticleURLs) > 0 {
+		result.ArticleURL = result.ArticleURLs[0]
+	}
+	// The article page is always extracted when it's given
+	if options.ArticleURL != "" || options.ArticleFile != "" {
+		fetchArticlePage = true
+	}
+	// The feed values belong to the first item only
+	if feedArticle != nil && options.ArticleURL != "" && feedArticle.URL != options.ArticleURL {
+		feedArticle = nil
+	}
+
+	if !fetchArticlePage {
+		if feedArticle == nil {
+			return nil, ErrNoArticles
+		}
+		result.ArticleURL = ""
+		result.Article = *feedArticle
+		result.EmptyFields = emptyArticleFields(result.Article, config.ArticleConfig.Fields)
+		return result, nil
+	}
+
+	if result.ArticleURL == "" && options.ArticleFile == "" {
+		return nil, ErrNoArticles
+	}
+
+	detailPage, err := n.openPage(result.ArticleURL, options.ArticleFile)
+	if err != nil {
+		return nil, err
+	}
+	defer detailPage.Close()
+
+	pageArticle, err := n.extractArticlePage(result.ArticleURL, detailPage)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Article = pageArticle
+	if feedArticle != nil {
+		result.Article = mergeArticles(*feedArticle, pageArticle)
+	}
+	result.EmptyFields = emptyArticleFields(result.Article, config.ArticleConfig.Fields)
+	return result, nil
+}
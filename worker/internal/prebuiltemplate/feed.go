@@ -0,0 +1,215 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/feed"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+// Text of the html which the feeds put into the summary and content
+func htmlText(source string) string {
+	if !strings.ContainsAny(source, "<&") {
+		return strings.TrimSpace(source)
+	}
+
+	doc, err := parser.ParseDocument(strings.NewReader(source))
+	if err != nil {
+		return strings.TrimSpace(source)
+	}
+	return strings.TrimSpace(doc.Root.Text())
+}
+
+// Resolve the url of the feed item against the feed url
+func resolveURL(base *url.URL, ref string) string {
+	if base == nil || ref == "" {
+		return ref
+	}
+	resolved, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return resolved.String()
+}
+
+func (n *NewsFeedProcessor) feedItemArticle(item feed.Item, base *url.URL, charsetName string) natsinfo.Article {
+	article := natsinfo.Article{
+		URL:         resolveURL(base, item.URL),
+		Title:       htmlText(item.Title),
+		Preface:     htmlText(item.Summary),
+		Content:     htmlText(item.Content),
+		PublishedAt: item.PublishedAt,
+		Authors:     item.Authors,
+		Origin:      n.origin,
+		Charset:     charsetName,
+	}
+
+	if len(item.Categories) > 0 {
+		article.Section = item.Categories[0]
+	}
+
+	for idx, image := range item.Images() {
+		image = resolveURL(base, image)
+		if idx == 0 {
+			article.MainImage = image
+			continue
+		}
+		article.ContentImages = append(article.ContentImages, image)
+	}
+
+	return article
+}
+
+// The feed values are kept, the article page fills the fields which the feed doesn't have
+func mergeArticles(feedArticle, pageArticle natsinfo.Article) natsinfo.Article {
+	if feedArticle.Title == "" {
+		feedArticle.Title = pageArticle.Title
+	}
+	if feedArticle.Preface == "" {
+		feedArticle.Preface = pageArticle.Preface
+	}
+	if feedArticle.Content == "" {
+		feedArticle.Content = pageArticle.Content
+	}
+	if feedArticle.PublishedAt.IsZero() {
+		feedArticle.PublishedAt = pageArticle.PublishedAt
+	}
+	if feedArticle.ViewersCount == 0 {
+		feedArticle.ViewersCount = pageArticle.ViewersCount
+	}
+	if feedArticle.MainImage == "" {
+		feedArticle.MainImage = pageArticle.MainImage
+	}
+	if len(feedArticle.ContentImages) == 0 {
+		feedArticle.ContentImages = pageArticle.ContentImages
+	}
+	if len(feedArticle.Authors) == 0 {
+		feedArticle.Authors = pageArticle.Authors
+	}
+	if feedArticle.Section == "" {
+		feedArticle.Section = pageArticle.Section
+	}
+	// The content is taken from the page, so its encoding is more relevant
+	if pageArticle.Charset != "" {
+		feedArticle.Charset = pageArticle.Charset
+	}
+	return feedArticle
+}
+
+// Key of the link-less feed item in the seen store. The item is keyed by its id or by its title and publish date,
+// they are unique only within the feed, so the key is scoped by the feed url.
+// The key is empty when nothing identifies the item.
+func (n *NewsFeedProcessor) feedItemKey(item feed.Item) string {
+	if item.ID != "" {
+		return n.config.NewsFeedURL + "#id:" + item.ID
+	}
+	if item.Title == "" && item.PublishedAt.IsZero() {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(item.Title + "\x00" + item.PublishedAt.UTC().Format(time.RFC3339)))
+	return n.config.NewsFeedURL + "#item:" + hex.EncodeToString(sum[:])
+}
+
+// Process the item of the rss, atom or json feed
+//
+// The feed supplies the article by itself. When the NewsFeedConfig.FetchArticlePage is set
+// the article page is pulled like the page of the html news feed.
+// The seen item is skipped until its re-fetch is due, the item without the url is seen by the feedItemKey.
+// The article page is queued to the crawl scheduler, so the refresh doesn't wait for it.
+func (n *NewsFeedProcessor) onFeedItem(ctx context.Context, item feed.Item, base *url.URL, charsetName string) {
+	article := n.feedItemArticle(item, base, charsetName)
+	if item.URL == "" {
+		key := n.feedItemKey(item)
+		if key == "" {
+			n.sendFeedArticle(ctx, article, "")
+			return
+		}
+		if due, _ := n.isDue(key); due {
+			n.sendFeedArticle(ctx, article, key)
+		}
+		return
+	}
+
+	due, unseen := n.isDue(article.URL)
+	if !due {
+		return
+	}
+
+	if !n.config.pullsArticlePages() {
+		n.sendFeedArticle(ctx, article, article.URL)
+		return
+	}
+
+	n.queuePull(ctx, &crawlTask{
+		url:         article.URL,
+		refetch:     !unseen,
+		publishedAt: article.PublishedAt,
+		pull:        func(ctx context.Context) { n.pullFeedArticle(ctx, article) },
+	})
+}
+
+// Pull the article page of the feed item. The feed article is sent even when the page is not pulled.
+func (n *NewsFeedProcessor) pullFeedArticle(ctx context.Context, article natsinfo.Article) {
+	pageArticle, err := n.pullArticlePage(ctx, article.URL)
+	switch {
+	case errors.Is(err, fetcher.ErrDisallowedByRobots):
+		// The blocked url is already logged
+	case err != nil:
+		log.Printf("Unable get article page at %s. Err: %s", article.URL, err)
+	default:
+		article = mergeArticles(article, pageArticle)
+	}
+	n.sendFeedArticle(ctx, article, article.URL)
+}
+
+// Send the feed article, it's remembered by the not empty seen key after it's sent
+func (n *NewsFeedProcessor) sendFeedArticle(ctx context.Context, article natsinfo.Article, seenKey string) {
+	// Unknown publish date is the time when the article is found
+	if article.PublishedAt.IsZero() {
+		article.PublishedAt = time.Now()
+	}
+	n.sendArticle(ctx, article)
+	if seenKey != "" && ctx.Err() == nil {
+		n.markSeen(seenKey)
+	}
+}
+
+func (n *NewsFeedProcessor) refreshFeed(ctx context.Context) error {
+	resp, err := n.getChangedRemotePage(ctx, n.config.NewsFeedURL)
+	if errors.Is(err, fetcher.ErrNotModified) {
+		log.Printf("News feed %s is not modified", n.config.NewsFeedURL)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	newsFeed, err := feed.Parse(resp, n.config.feedType())
+	if err != nil {
+		return err
+	}
+
+	base, err := url.Parse(n.config.NewsFeedURL)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Found %d items in %s feed %s", len(newsFeed.Items), n.config.feedType(), n.config.NewsFeedURL)
+	for _, item := range newsFeed.Items {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n.onFeedItem(ctx, item, base, resp.charset)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package prebuiltemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+)
+
+// Files of the fixture directory
+const (
+	// template.json, template.yaml or template.yml
+	FIXTURE_TEMPLATE_NAME = "template"
+	// Saved news feed page, like feed.html, feed.xml or feed.json
+	FIXTURE_FEED_NAME = "feed"
+	// Saved article page, it's served for each article url of the news feed
+	FIXTURE_ARTICLE_NAME = "article.html"
+	// The expected dry run result
+	FIXTURE_EXPECTED_NAME = "expected.json"
+)
+
+var (
+	ErrFixtureMismatch = errors.New("fixture result mismatch")
+	ErrNoFixtureFile   = errors.New("fixture file not found")
+)
+
+// Fixture is the saved pages of the source with the article which is expected from them.
+// When the source changes markup the fixture of its template is updated to the new pages.
+type Fixture struct {
+	Name string
+	Dir  string
+}
+
+// Fixtures of the directory, each of them is the subdirectory with the template file
+func LoadFixtures(dir string) ([]Fixture, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixtures []Fixture
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		fixture := Fixture{Name: entry.Name(), Dir: filepath.Join(dir, entry.Name())}
+		if _, err := fixture.file(FIXTURE_TEMPLATE_NAME); err != nil {
+			continue
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures, nil
+}
+
+// Path of the fixture file by its name without the extension
+func (f Fixture) file(name string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(f.Dir, name+".*"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%w %s in %s", ErrNoFixtureFile, name, f.Dir)
+	}
+	return matches[0], nil
+}
+
+func (f Fixture) template() (NewsFeedConfig, error) {
+	path, err := f.file(FIXTURE_TEMPLATE_NAME)
+	if err != nil {
+		return NewsFeedConfig{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NewsFeedConfig{}, err
+	}
+	configs, err := parseValidTemplates(path, data)
+	if err != nil {
+		return NewsFeedConfig{}, err
+	}
+	if len(configs) != 1 {
+		return NewsFeedConfig{}, fmt.Errorf("fixture must have the single template, got %d", len(configs))
+	}
+	return configs[0], nil
+}
+
+func fixtureContentType(path string) string {
+	switch filepath.Ext(path) {
+	case ".xml":
+		return "application/xml"
+	case ".json":
+		return "application/feed+json"
+	}
+	return "text/html"
+}
+
+// Serve the saved news feed at the path of the news feed url, other paths are the saved article.
+// The robots.txt is missing, so each page is allowed.
+func (f Fixture) handler(feedURL *url.URL) (http.Handler, error) {
+	feedPath, err := f.file(FIXTURE_FEED_NAME)
+	if err != nil {
+		return nil, err
+	}
+	articlePath := filepath.Join(f.Dir, FIXTURE_ARTICLE_NAME)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := articlePath
+		switch r.URL.Path {
+		case feedURL.Path:
+			path = feedPath
+		case fetcher.ROBOTS_PATH:
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", fixtureContentType(path))
+		w.Write(data)
+	}), nil
+}
+
+// Route the requests of each host to the fixture server, so the saved pages keep their urls
+type fixtureTransport struct {
+	server *url.URL
+}
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	routed := req.Clone(req.Context())
+	routed.URL.Scheme = t.server.Scheme
+	routed.URL.Host = t.server.Host
+	return http.DefaultTransport.RoundTrip(routed)
+}
+
+// Replay the saved pages through the template by the httptest server. The result is the indented json.
+func (f Fixture) Replay() ([]byte, error) {
+	config, err := f.template()
+	if err != nil {
+		return nil, err
+	}
+	feedURL, err := url.Parse(config.NewsFeedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	handler, err := f.handler(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	result, err := DryRun(config, DryRunOptions{Transport: fixtureTransport{server: serverURL}})
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Lines of the expected and the actual result which differ
+func diffLines(expected, actual []byte) string {
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(string(actual), "\n")
+
+	var diff strings.Builder
+	for idx := 0; idx < len(expectedLines) || idx < len(actualLines); idx++ {
+		var expectedLine, actualLine string
+		if idx < len(expectedLines) {
+			expectedLine = expectedLines[idx]
+		}
+		if idx < len(actualLines) {
+			actualLine = actualLines[idx]
+		}
+		if expectedLine == actualLine {
+			continue
+		}
+		fmt.Fprintf(&diff, "line %d:\n- %s\n+ %s\n", idx+1, expectedLine, actualLine)
+	}
+	return diff.String()
+}
+
+// Compare the replayed result with the expected one. The update writes the result as the expected one.
+func (f Fixture) Check(update bool) error {
+	actual, err := f.Replay()
+	if err != nil {
+		return err
+	}
+
+	expectedPath := filepath.Join(f.Dir, FIXTURE_EXPECTED_NAME)
+	if update {
+		return os.WriteFile(expectedPath, actual, 0644)
+	}
+
+	expected, err := os.ReadFile(expectedPath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("%w:\n%s", ErrFixtureMismatch, diffLines(expected, actual))
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package prebuiltemplate
+
+import (
+	"flag"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Write the replayed fixture results as the expected ones")
+
+// Replay the golden fixtures of testdata
+//
+// Example: go test ./internal/prebuiltemplate -run TestFixtures/html-news -update
+func TestFixtures(t *testing.T) {
+	fixtures, err := LoadFixtures("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			if err := fixture.Check(*update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
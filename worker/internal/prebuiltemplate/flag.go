@@ -1,20 +1,35 @@
 package prebuiltemplate
 
 import (
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 )
 
+// Templates of the flag. The flag is the json template or the path to the template file or directory.
 type ConfigFlag []NewsFeedConfig
 
 func (c *ConfigFlag) Set(arg string) error {
-	var config NewsFeedConfig
-	if err := json.Unmarshal([]byte(arg), &config); err != nil {
+	if trimmed := strings.TrimSpace(arg); !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		configs, err := LoadTemplates(trimmed)
+		if err != nil {
+			return err
+		}
+		*c = append(*c, configs...)
+		return nil
+	}
+
+	configs, err := parseTemplates([]byte(arg))
+	if err != nil {
 		return errors.Join(errors.New("unable deserialize config."), err)
 	}
-	*c = append(*c, config)
+	for _, config := range configs {
+		if err := config.Validate(); err != nil {
+			return err
+		}
+	}
+	*c = append(*c, configs...)
 	return nil
 }
 
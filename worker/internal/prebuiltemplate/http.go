@@ -0,0 +1,45 @@
+package prebuiltemplate
+
+import (
+	"time"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+)
+
+// Http client of the news feed and article pages. The zero values are the defaults of the fetcher package.
+type HTTPConfig struct {
+	// Timeout of the single request attempt
+	Timeout Duration `json:"timeout"`
+	// Retries of the network errors, 5xx and 429 responses. The negative value disables the retries.
+	MaxRetries int `json:"max_retries"`
+	// Backoff of the first retry, it's doubled for each next one up to the MaxRetryBackoff
+	RetryBackoff    Duration `json:"retry_backoff"`
+	MaxRetryBackoff Duration `json:"max_retry_backoff"`
+	UserAgent       string   `json:"user_agent"`
+	// Requests per second to the single host, the negative value disables the limit
+	RateLimit float64 `json:"rate_limit"`
+	RateBurst int     `json:"rate_burst"`
+	// Pull the pages which are disallowed by the robots.txt, only for the sites which allowed it
+	IgnoreRobots bool `json:"ignore_robots"`
+	// How long the robots.txt of the site is cached
+	RobotsTTL Duration `json:"robots_ttl"`
+	// Disk cache of the pages for the development, the cached page is not requested again until the CacheTTL
+	CacheDir string   `json:"cache_dir"`
+	CacheTTL Duration `json:"cache_ttl"`
+}
+
+func (c HTTPConfig) fetcherConfig() fetcher.Config {
+	return fetcher.Config{
+		Timeout:      time.Duration(c.Timeout),
+		MaxRetries:   c.MaxRetries,
+		Backoff:      time.Duration(c.RetryBackoff),
+		MaxBackoff:   time.Duration(c.MaxRetryBackoff),
+		UserAgent:    c.UserAgent,
+		RateLimit:    c.RateLimit,
+		RateBurst:    c.RateBurst,
+		IgnoreRobots: c.IgnoreRobots,
+		RobotsTTL:    time.Duration(c.RobotsTTL),
+		CacheDir:     c.CacheDir,
+		CacheTTL:     time.Duration(c.CacheTTL),
+	}
+}
@@ -2,23 +2,84 @@ package prebuiltemplate
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"strings"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/charset"
+	"github.com/romashorodok/news-tracker/worker/pkg/feed"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
 	"github.com/romashorodok/news-tracker/worker/pkg/parser"
 	"github.com/romashorodok/news-tracker/worker/pkg/parser/selector"
 )
 
-func getRemotePage(path string) (io.ReadCloser, error) {
-	resp, err := http.Get(path)
+// Host of the url, like `example.com`. It's empty for the url without the host, like the relative or `javascript:` link.
+func originOf(link string) string {
+	parsed, err := url.Parse(link)
 	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// Remote page body transcoded to the utf-8
+type remotePage struct {
+	io.Reader
+	body    io.Closer
+	charset string
+}
+
+func (p *remotePage) Close() error {
+	return p.body.Close()
+}
+
+// Count and log the url which is not pulled because of the robots.txt
+func (n *NewsFeedProcessor) onBlockedURL(url string) {
+	blocked := n.blockedURLs.Add(1)
+	log.Printf("Skip %s disallowed by robots.txt. Blocked urls: %d", url, blocked)
+}
+
+func (n *NewsFeedProcessor) getRemotePage(ctx context.Context, path string) (*remotePage, error) {
+	resp, err := n.fetcher.Get(ctx, path)
+	return n.openRemotePage(path, resp, err)
+}
+
+// The news feed page which is changed since its last refresh, otherwise the error is fetcher.ErrNotModified.
+// The re-fetch of the seen articles needs their news feed page, so the page is always pulled with it.
+func (n *NewsFeedProcessor) getChangedRemotePage(ctx context.Context, path string) (*remotePage, error) {
+	if n.config.Refetch.Interval > 0 {
+		return n.getRemotePage(ctx, path)
+	}
+	resp, err := n.fetcher.GetIfModified(ctx, path)
+	return n.openRemotePage(path, resp, err)
+}
+
+func (n *NewsFeedProcessor) openRemotePage(path string, resp *http.Response, err error) (*remotePage, error) {
+	if errors.Is(err, fetcher.ErrDisallowedByRobots) {
+		n.onBlockedURL(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, charsetName, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		resp.Body.Close()
 		return nil, err
 	}
-	return resp.Body, nil
+
+	return &remotePage{
+		Reader:  body,
+		body:    resp.Body,
+		charset: charsetName,
+	}, nil
 }
 
 type ArticleConfig struct {
@@ -28,20 +89,78 @@ type ArticleConfig struct {
 type NewsFeedConfig struct {
 	NewsFeedURL             string   `json:"news_feed_url"`
 	NewsFeedArticleSelector []string `json:"news_feed_article_selector"`
-	NewsFeedRefreshInterval int      `json:"news_feed_refresh_interval"`
+	// Takes precedence over the NewsFeedArticleSelector
+	NewsFeedArticleCssSelector string   `json:"news_feed_article_css_selector"`
+	NewsFeedRefreshInterval    Duration `json:"news_feed_refresh_interval"`
+
+	ArticlePrefixURL string        `json:"article_prefix_url"`
+	ArticleConfig    ArticleConfig `json:"article_config"`
+	// Delay between the article pulls of the single host
+	ArticlePullInterval Duration `json:"article_pull_interval"`
+	ArticlePageSelector []string `json:"article_page_selector"`
+	// Takes precedence over the ArticlePageSelector
+	ArticlePageCssSelector string `json:"article_page_css_selector"`
+
+	// Keep the html character references like `&quot;` in the extracted values. Useful for debugging.
+	RawEntities bool `json:"raw_entities"`
+	// Log each parse warning of the pages instead of the warnings count
+	LogParseWarnings bool `json:"log_parse_warnings"`
+
+	// Type of the news feed page, the html by default.
+	// The rss, atom and jsonfeed supply the article urls, titles, dates and images by themselves.
+	FeedType string `json:"feed_type"`
+	// Get the article page of each feed item and fill the fields which the feed doesn't have by the ArticleConfig
+	FetchArticlePage bool `json:"fetch_article_page"`
+	// Limits of the sitemap feed type. The NewsFeedURL is the sitemap, sitemap index or the robots.txt with sitemaps.
+	Sitemap SitemapConfig `json:"sitemap"`
+
+	// The css selector of the next page link on the news feed page, like `a[rel=next]`
+	NewsFeedNextPageSelector string `json:"news_feed_next_page_selector"`
+	// Used when the NewsFeedNextPageSelector is not set. The `{n}` is replaced by the page number, the NewsFeedURL is the page 1.
+	// Example: `https://example.com/news?page={n}`
+	NewsFeedPageURLPattern string `json:"news_feed_page_url_pattern"`
+	// How many news feed pages are walked on each refresh. The walk also stops at the page which articles are already pulled.
+	NewsFeedMaxDepth int `json:"news_feed_max_depth"`
 
-	ArticlePrefixURL    string        `json:"article_prefix_url"`
-	ArticleConfig       ArticleConfig `json:"article_config"`
-	ArticlePullInterval int           `json:"article_pull_interval"`
-	ArticlePageSelector []string      `json:"article_page_selector"`
+	// Timeouts, retries, rate limit and User-Agent of the requests to the source
+	HTTP HTTPConfig `json:"http"`
+	// Pull the seen articles again for a while after their first pull
+	Refetch RefetchConfig `json:"refetch"`
+}
+
+func (c NewsFeedConfig) feedType() string {
+	if c.FeedType == "" {
+		return feed.TYPE_HTML
+	}
+	return c.FeedType
+}
+
+// The html and sitemap feeds have only the article urls, the other feeds pull the article page when it's asked
+func (c NewsFeedConfig) pullsArticlePages() bool {
+	switch c.feedType() {
+	case feed.TYPE_HTML, feed.TYPE_SITEMAP:
+		return true
+	}
+	return c.FetchArticlePage
 }
 
 type NewsFeedProcessor struct {
-	ArticleChan                   chan natsinfo.Article
-	newsFeedRefreshIntervalTicker *time.Ticker
-	articlePullIntervalTicker     *time.Ticker
-	config                        NewsFeedConfig
-	origin                        string
+	ArticleChan chan natsinfo.Article
+	config      NewsFeedConfig
+	origin      string
+	// Article pages which are already pulled
+	seen    SeenStore
+	fetcher *fetcher.Fetcher
+	// Urls which are disallowed by the robots.txt
+	blockedURLs atomic.Int64
+	// Pulls the article pages, it's shared by the processors of the supervisor
+	scheduler *crawlScheduler
+	// The processor runs the scheduler by itself when it's not shared
+	ownScheduler bool
+	// Queued article pulls, the ArticleChan is closed once they are done
+	pulls sync.WaitGroup
+	// Unseen article urls which are queued by the last scrape of each news feed page
+	unseenPageURLs map[string][]string
 }
 
 // Process the article node which point to the detail page
@@ -49,46 +168,63 @@ type NewsFeedProcessor struct {
 // Example: Each article container on feed has something which point to the actual page of the article
 // <li><a class="article-button" href="http://.../article/id">{Some title}</a></li>
 // NewsFeedConfig.ArticlePageSelector must be the `article-button` to select the node here
-func (n *NewsFeedProcessor) onArticlePageNode(node *parser.Node) {
+func (n *NewsFeedProcessor) onArticlePageNode(page *newsFeedPage, node *parser.Node) {
+	page.addArticleURL(n.config.ArticlePrefixURL + node.Tag.Attr["href"])
+}
+
+func (n *NewsFeedProcessor) sendArticle(ctx context.Context, article natsinfo.Article) {
 	select {
-	case <-n.articlePullIntervalTicker.C:
-		url := n.config.ArticlePrefixURL + node.Tag.Attr["href"]
-		log.Println("Get article page at", url)
+	case <-ctx.Done():
+	case n.ArticleChan <- article:
+	}
+}
 
-		detailPage, err := getRemotePage(url)
-		if err != nil {
-			log.Println("Unable get remote article page at")
-			return
-		}
-		defer detailPage.Close()
-		detailPageExtractor := NewArticlePageExtractor(n.config)
-
-		var selectors []parser.Selector
-
-		for _, field := range n.config.ArticleConfig.Fields {
-			switch field.Type {
-			case FIELD_TYPE_TITLE:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnTitle(field)))
-			case FIELD_TYPE_CONTENT:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnContent(field)))
-			case FIELD_TYPE_PREFACE:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnPreface(field)))
-			case FIELD_TYPE_PUBLISHED_AT:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnPublishDate(field)))
-			case FIELD_TYPE_INFO:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnInfo(field)))
-			case FIELD_TYPE_MAIN_IMAGE:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnMainImage(field)))
-			case FIELD_TYPE_CONTENT_IMAGES:
-				selectors = append(selectors, selector.NewClassSelector([]string{field.ClassSelector}, detailPageExtractor.OnContentImages(field)))
-			}
-		}
+// Pull the article page which is found on the news feed page
+func (n *NewsFeedProcessor) onArticleURL(ctx context.Context, url string) {
+	article, err := n.pullArticlePage(ctx, url)
+	// The blocked url is seen, so it's not queued again on each refresh
+	if errors.Is(err, fetcher.ErrDisallowedByRobots) {
+		n.markSeen(url)
+		return
+	}
+	if err != nil {
+		log.Printf("Unable get article page at %s. Err: %s", url, err)
+		return
+	}
+	n.markSeen(url)
+
+	// Unknown publish date is the time when the article is found
+	if article.PublishedAt.IsZero() {
+		article.PublishedAt = time.Now()
+	}
+	n.sendArticle(ctx, article)
+}
+
+// Get the article page and extract its fields by the ArticleConfig
+func (n *NewsFeedProcessor) pullArticlePage(ctx context.Context, url string) (natsinfo.Article, error) {
+	log.Println("Get article page at", url)
+
+	detailPage, err := n.getRemotePage(ctx, url)
+	if err != nil {
+		return natsinfo.Article{}, err
+	}
+	defer detailPage.Close()
+	return n.extractArticlePage(url, detailPage)
+}
 
-		parser.Parse(detailPage, selectors...)
-		article := detailPageExtractor.article
-		article.Origin = n.origin
-		n.ArticleChan <- article
+// Extract the fields of the article page by the ArticleConfig
+func (n *NewsFeedProcessor) extractArticlePage(url string, detailPage *remotePage) (natsinfo.Article, error) {
+	doc, err := parser.ParseDocumentWithOptions(detailPage, n.parseOptions())
+	if err != nil {
+		return natsinfo.Article{}, err
 	}
+	n.logDiagnostics(url, doc.Diagnostics)
+
+	article := NewArticlePageExtractor(n.config).Extract(doc)
+	article.URL = url
+	article.Origin = n.origin
+	article.Charset = detailPage.charset
+	return article, nil
 }
 
 // Process each article items on news feed page
@@ -100,14 +236,52 @@ func (n *NewsFeedProcessor) onArticlePageNode(node *parser.Node) {
 // <ol><li class="article-item"></li><li class="article-itme"></li></ol>
 //
 // NewsFeedConfig.NewsFeedArticleSelector must be the `article-item` to select that nodes here
-func (n *NewsFeedProcessor) onNewsFeedArticleNode(node *parser.Node) {
-	for node := node; node != nil; node = node.Next {
-		classesStr := node.Tag.Attr["class"]
-		// Find the node which contain element which point to the article page.
-		if parser.ContainsClass(classesStr, n.config.ArticlePageSelector) {
-			n.onArticlePageNode(node)
-			break
+func (n *NewsFeedProcessor) onNewsFeedArticleNode(page *newsFeedPage, node *parser.Node) {
+	// Find the node which contain element which point to the article page.
+	if articlePageNode := node.Find(parser.ByClass(n.config.ArticlePageSelector...)); articlePageNode != nil {
+		n.onArticlePageNode(page, articlePageNode)
+	}
+}
+
+// Select the news feed article items or the links to the article page directly.
+//
+// When both css selectors are present they are joined by the descendant combinator.
+// Example: `ol.news > li` and `a.article-button` select `ol.news > li a.article-button`
+func (n *NewsFeedProcessor) newsFeedSelector(page *newsFeedPage) (parser.Selector, error) {
+	onArticlePageNode := func(node *parser.Node) { n.onArticlePageNode(page, node) }
+	onNewsFeedArticleNode := func(node *parser.Node) { n.onNewsFeedArticleNode(page, node) }
+
+	switch {
+	case n.config.ArticlePageCssSelector != "":
+		query := n.config.ArticlePageCssSelector
+		if n.config.NewsFeedArticleCssSelector != "" {
+			query = n.config.NewsFeedArticleCssSelector + " " + query
 		}
+		return selector.NewCssSelector(query, onArticlePageNode)
+
+	case n.config.NewsFeedArticleCssSelector != "":
+		return selector.NewCssSelector(n.config.NewsFeedArticleCssSelector, onNewsFeedArticleNode)
+	}
+
+	return selector.NewClassSelector(n.config.NewsFeedArticleSelector, onNewsFeedArticleNode), nil
+}
+
+func (n *NewsFeedProcessor) parseOptions() parser.ParseOptions {
+	return parser.ParseOptions{RawEntities: n.config.RawEntities}
+}
+
+func (n *NewsFeedProcessor) logDiagnostics(url string, diagnostics parser.Diagnostics) {
+	if len(diagnostics) == 0 {
+		return
+	}
+
+	if !n.config.LogParseWarnings {
+		log.Printf("Parsed %s with %d warnings. First: %s", url, len(diagnostics), diagnostics[0])
+		return
+	}
+
+	for _, diagnostic := range diagnostics {
+		log.Printf("Parse warning at %s %s", url, diagnostic)
 	}
 }
 
@@ -115,41 +289,81 @@ func (n *NewsFeedProcessor) GetArticleChan() <-chan natsinfo.Article {
 	return n.ArticleChan
 }
 
+// Refresh the news feed on each tick. The found articles are pulled by the crawl scheduler meanwhile,
+// so the refresh doesn't wait for them. The ArticleChan is closed when the queued pulls are done or dropped too.
 func (n *NewsFeedProcessor) Start(ctx context.Context) {
 	defer close(n.ArticleChan)
-	url := n.config.NewsFeedURL
-	n.origin = strings.Split(strings.SplitAfter(url, "//")[1], "/")[0]
+	defer n.pulls.Wait()
+	defer n.scheduler.dropCanceled()
+	newsFeedRefreshIntervalTicker := time.NewTicker(time.Duration(n.config.NewsFeedRefreshInterval))
+	defer newsFeedRefreshIntervalTicker.Stop()
+	n.origin = originOf(n.config.NewsFeedURL)
+
+	if n.ownScheduler {
+		var scheduler sync.WaitGroup
+		defer scheduler.Wait()
+		scheduler.Add(1)
+		go func() {
+			defer scheduler.Done()
+			n.scheduler.run(ctx)
+		}()
+	}
+
 	for {
 		log.Printf("Next news feed refresh at %s", time.Now().Add(time.Duration(n.config.NewsFeedRefreshInterval)))
 		select {
 		case <-ctx.Done():
 			return
-		case <-n.newsFeedRefreshIntervalTicker.C:
+		case <-newsFeedRefreshIntervalTicker.C:
 			log.Println("Refresh news feed page", n.config.NewsFeedURL)
-			resp, err := getRemotePage(n.config.NewsFeedURL)
-			if err != nil {
-				log.Println("Unable get remote news feed page at", n.config.NewsFeedURL)
+			if err := n.refresh(ctx); err != nil {
+				// The processor is stopped in the middle of the refresh
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Unable refresh news feed page %s. Err: %s", n.config.NewsFeedURL, err)
 				continue
 			}
-			parser.Parse(resp, selector.NewClassSelector(
-				n.config.NewsFeedArticleSelector,
-				n.onNewsFeedArticleNode,
-			))
-			resp.Close()
 			log.Printf("Done news feed page refresh for %s", n.config.NewsFeedURL)
 		}
 	}
 }
 
-func NewNewsFeedProcessor(config NewsFeedConfig) *NewsFeedProcessor {
+func (n *NewsFeedProcessor) refresh(ctx context.Context) error {
+	switch n.config.feedType() {
+	case feed.TYPE_HTML:
+		return n.refreshPages(ctx)
+	case feed.TYPE_SITEMAP:
+		return n.refreshSitemaps(ctx)
+	case feed.TYPE_RSS, feed.TYPE_ATOM, feed.TYPE_JSONFEED:
+		return n.refreshFeed(ctx)
+	}
+	return fmt.Errorf("%w %q", feed.ErrUnknownFeedType, n.config.FeedType)
+}
+
+func newNewsFeedProcessor(config NewsFeedConfig, seen SeenStore, sharedFetcher *fetcher.Fetcher, scheduler *crawlScheduler) *NewsFeedProcessor {
+	if seen == nil {
+		seen = newMemorySeenStore()
+	}
+	if sharedFetcher == nil {
+		sharedFetcher = fetcher.New(fetcher.Config{})
+	}
 	return &NewsFeedProcessor{
-		newsFeedRefreshIntervalTicker: time.NewTicker(
-			time.Duration(config.NewsFeedRefreshInterval),
-		),
-		articlePullIntervalTicker: time.NewTicker(
-			time.Duration(config.ArticlePullInterval),
-		),
-		config:      config,
-		ArticleChan: make(chan natsinfo.Article),
+		scheduler:      scheduler,
+		config:         config,
+		ArticleChan:    make(chan natsinfo.Article),
+		seen:           seen,
+		unseenPageURLs: make(map[string][]string),
+		fetcher:        sharedFetcher.With(config.HTTP.fetcherConfig()),
 	}
 }
+
+// The seen store keeps the pulled article urls, the processor keeps them in memory when it's nil.
+// The pages are pulled by the handle of the shared fetcher with the HTTPConfig of the template,
+// the processor has its own fetcher when it's nil.
+// The processor runs its own crawl scheduler with the DEFAULT_ARTICLE_WORKERS, the Supervisor shares one instead.
+func NewNewsFeedProcessor(config NewsFeedConfig, seen SeenStore, sharedFetcher *fetcher.Fetcher) *NewsFeedProcessor {
+	processor := newNewsFeedProcessor(config, seen, sharedFetcher, newCrawlScheduler(DEFAULT_ARTICLE_WORKERS))
+	processor.ownScheduler = true
+	return processor
+}
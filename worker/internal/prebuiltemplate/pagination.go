@@ -0,0 +1,175 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/selector"
+)
+
+const (
+	DEFAULT_NEWS_FEED_MAX_DEPTH = 10
+	// Placeholder of the page number in the NewsFeedConfig.NewsFeedPageURLPattern
+	PAGE_NUMBER_PLACEHOLDER = "{n}"
+)
+
+// Links found on the single news feed page
+type newsFeedPage struct {
+	url         string
+	articleURLs []string
+	nextPageURL string
+}
+
+// The link without the host is skipped, like the relative link with the wrong ArticlePrefixURL
+func (p *newsFeedPage) addArticleURL(articleURL string) {
+	if originOf(articleURL) == "" {
+		log.Printf("Skip article link %q without the host on news feed page %s", articleURL, p.url)
+		return
+	}
+	p.articleURLs = append(p.articleURLs, articleURL)
+}
+
+// The first link is the next page, the pagination often repeats at the top and bottom of the page
+func (p *newsFeedPage) onNextPageNode(node *parser.Node) {
+	if p.nextPageURL != "" {
+		return
+	}
+	if href := node.Tag.Attr["href"]; href != "" {
+		base, _ := url.Parse(p.url)
+		// The link without the host can't be pulled, like the `javascript:` one
+		if next := resolveURL(base, href); originOf(next) != "" {
+			p.nextPageURL = next
+		}
+	}
+}
+
+func (c NewsFeedConfig) isPaginated() bool {
+	return c.NewsFeedNextPageSelector != "" || c.NewsFeedPageURLPattern != ""
+}
+
+func (c NewsFeedConfig) maxDepth() int {
+	if !c.isPaginated() {
+		return 1
+	}
+	if c.NewsFeedMaxDepth <= 0 {
+		return DEFAULT_NEWS_FEED_MAX_DEPTH
+	}
+	return c.NewsFeedMaxDepth
+}
+
+// Url of the page after the page at the depth. The NewsFeedURL is the page 1.
+func (c NewsFeedConfig) nextPageURL(page *newsFeedPage, depth int) string {
+	if c.NewsFeedNextPageSelector != "" {
+		return page.nextPageURL
+	}
+	if c.NewsFeedPageURLPattern != "" {
+		return strings.ReplaceAll(c.NewsFeedPageURLPattern, PAGE_NUMBER_PLACEHOLDER, strconv.Itoa(depth+1))
+	}
+	return ""
+}
+
+// Scrape the article links and the next page link of the news feed page.
+// The page which articles of the last scrape are neither pulled nor queued is pulled without the conditional request,
+// because their pulls failed and the not modified page would never queue them again.
+func (n *NewsFeedProcessor) scrapeNewsFeedPage(ctx context.Context, pageURL string) (*newsFeedPage, error) {
+	getPage := n.getChangedRemotePage
+	if unseen, _ := n.dueArticleURLs(n.unseenPageURLs[pageURL]); len(unseen) > 0 {
+		getPage = n.getRemotePage
+	}
+
+	resp, err := getPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	return n.scrapeNewsFeedReader(pageURL, resp)
+}
+
+func (n *NewsFeedProcessor) scrapeNewsFeedReader(pageURL string, source io.Reader) (*newsFeedPage, error) {
+	page := &newsFeedPage{url: pageURL}
+
+	newsFeedSelector, err := n.newsFeedSelector(page)
+	if err != nil {
+		return nil, err
+	}
+	selectors := []parser.Selector{newsFeedSelector}
+
+	if n.config.NewsFeedNextPageSelector != "" {
+		nextPageSelector, err := selector.NewCssSelector(n.config.NewsFeedNextPageSelector, page.onNextPageNode)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, nextPageSelector)
+	}
+
+	diagnostics := parser.ParseWithOptions(source, n.parseOptions(), selectors...)
+	n.logDiagnostics(pageURL, diagnostics)
+	return page, nil
+}
+
+// Queue the article urls of the news feed page to the crawl scheduler
+func (n *NewsFeedProcessor) queueArticleURLs(ctx context.Context, urls []string, depth int, refetch bool) {
+	for _, articleURL := range urls {
+		articleURL := articleURL
+		n.queuePull(ctx, &crawlTask{
+			url:     articleURL,
+			refetch: refetch,
+			depth:   depth,
+			pull:    func(ctx context.Context) { n.onArticleURL(ctx, articleURL) },
+		})
+	}
+}
+
+// Walk the news feed pages from the NewsFeedURL
+//
+// The walk stops at the NewsFeedConfig.NewsFeedMaxDepth, at the page which articles are already pulled
+// or at the page which is not modified since the last refresh.
+// So the first run walks the archive, and the next runs pull only the front page.
+func (n *NewsFeedProcessor) refreshPages(ctx context.Context) error {
+	pageURL := n.config.NewsFeedURL
+	visited := make(map[string]struct{})
+	for depth := 1; pageURL != ""; depth++ {
+		// The last page may link to itself or back to the first page
+		if _, ok := visited[pageURL]; ok {
+			return nil
+		}
+		visited[pageURL] = struct{}{}
+
+		page, err := n.scrapeNewsFeedPage(ctx, pageURL)
+		if errors.Is(err, fetcher.ErrNotModified) {
+			log.Printf("News feed page %s is not modified, stop at depth %d", pageURL, depth)
+			return nil
+		}
+		if err != nil {
+			if depth == 1 {
+				return err
+			}
+			log.Printf("Unable get news feed page %s. Err: %s", pageURL, err)
+			return nil
+		}
+
+		unseen, refetch := n.dueArticleURLs(page.articleURLs)
+		n.unseenPageURLs[pageURL] = unseen
+		n.queueArticleURLs(ctx, unseen, depth, false)
+		n.queueArticleURLs(ctx, refetch, depth, true)
+
+		if len(unseen) == 0 {
+			log.Printf("No new articles on news feed page %s, stop at depth %d", pageURL, depth)
+			return nil
+		}
+
+		if depth >= n.config.maxDepth() {
+			log.Printf("Reached max depth %d at news feed page %s", depth, pageURL)
+			return nil
+		}
+		pageURL = n.config.nextPageURL(page, depth)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package prebuiltemplate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/selector"
+)
+
+func TestOriginOf(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/news?page=2": "example.com",
+		"http://example.com:8080":         "example.com:8080",
+		"/news?page=2":                    "",
+		"news/item":                       "",
+		"javascript:void(0)":              "",
+		"":                                "",
+	}
+	for link, want := range tests {
+		if got := originOf(link); got != want {
+			t.Errorf("originOf(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func TestNewsFeedPageSkipsLinksWithoutHost(t *testing.T) {
+	page := &newsFeedPage{url: "https://example.com/news"}
+	page.addArticleURL("https://example.com/a/1")
+	// The wrong ArticlePrefixURL leaves the link relative
+	page.addArticleURL("/a/2")
+	if want := []string{"https://example.com/a/1"}; !slices.Equal(page.articleURLs, want) {
+		t.Errorf("got article urls %q, want %q", page.articleURLs, want)
+	}
+
+	next, err := selector.NewCssSelector("a[rel=next]", page.onNextPageNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser.Parse(strings.NewReader(`<a rel="next" href="javascript:void(0)">more</a><a rel="next" href="?page=2">2</a>`), next)
+	if want := "https://example.com/news?page=2"; page.nextPageURL != want {
+		t.Errorf("got next page %q, want %q", page.nextPageURL, want)
+	}
+}
@@ -0,0 +1,274 @@
+package prebuiltemplate
+
+import (
+	"container/heap"
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const DEFAULT_ARTICLE_WORKERS = 2
+
+// Article pull which waits in the queue of its host
+type crawlTask struct {
+	url  string
+	host string
+	// The new articles go before the re-fetched ones
+	refetch bool
+	// Page of the news feed where the article is found, the first page goes first
+	depth int
+	// The newer articles go first, the articles without the date go last
+	publishedAt time.Time
+	// The earlier found articles go first, like the links at the top of the page
+	seq uint64
+	// The ArticlePullInterval of the template, the next pull of the host is delayed by it
+	delay time.Duration
+	// Lifetime of the processor which queued the task, the task is dropped when it's done
+	ctx  context.Context
+	pull func(ctx context.Context)
+	// Called once the task is pulled or dropped
+	finish func()
+}
+
+func (t *crawlTask) before(other *crawlTask) bool {
+	switch {
+	case t.refetch != other.refetch:
+		return !t.refetch
+	case t.depth != other.depth:
+		return t.depth < other.depth
+	case t.publishedAt.IsZero() != other.publishedAt.IsZero():
+		return !t.publishedAt.IsZero()
+	case !t.publishedAt.Equal(other.publishedAt):
+		return t.publishedAt.After(other.publishedAt)
+	}
+	return t.seq < other.seq
+}
+
+// Priority queue of the host tasks
+type crawlQueue []*crawlTask
+
+func (q crawlQueue) Len() int           { return len(q) }
+func (q crawlQueue) Less(i, j int) bool { return q[i].before(q[j]) }
+func (q crawlQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *crawlQueue) Push(x any)        { *q = append(*q, x.(*crawlTask)) }
+func (q *crawlQueue) Pop() any {
+	old := *q
+	task := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return task
+}
+
+type crawlHost struct {
+	queue crawlQueue
+	// The host is pulled right now, so other workers don't pull it at once
+	busy bool
+	// The next pull of the host is not earlier than that
+	next time.Time
+}
+
+// Crawl scheduler of the templates. It decouples the news feed refresh from the article pulls.
+//
+// The found article urls go to the priority queue of their host, the workers pull them.
+// The pulls of the single host go one by one with the delay of the pulled task between them, the pulls of different hosts go at once.
+// So the templates of the same host share its queue, and the workers are shared by all templates.
+type crawlScheduler struct {
+	workers int
+
+	mu     sync.Mutex
+	hosts  map[string]*crawlHost
+	queued map[string]struct{}
+	seq    uint64
+	wake   chan struct{}
+}
+
+func newCrawlScheduler(workers int) *crawlScheduler {
+	if workers <= 0 {
+		workers = DEFAULT_ARTICLE_WORKERS
+	}
+	return &crawlScheduler{
+		workers: workers,
+		hosts:   make(map[string]*crawlHost),
+		queued:  make(map[string]struct{}),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Queue the article pull of the processor. It's pulled by the ctx of the processor and delayed by its ArticlePullInterval.
+func (n *NewsFeedProcessor) queuePull(ctx context.Context, task *crawlTask) {
+	task.ctx = ctx
+	task.delay = time.Duration(n.config.ArticlePullInterval)
+	task.finish = n.pulls.Done
+	n.pulls.Add(1)
+	if !n.scheduler.add(task) {
+		n.pulls.Done()
+	}
+}
+
+func (s *crawlScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// The url waits in the queue or it's pulled right now
+func (s *crawlScheduler) isQueued(articleURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.queued[articleURL]
+	return ok
+}
+
+// Queue the task. The queued url is skipped, then false is returned.
+func (s *crawlScheduler) add(task *crawlTask) bool {
+	task.host = task.url
+	if parsed, err := url.Parse(task.url); err == nil {
+		task.host = parsed.Host
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.queued[task.url]; ok {
+		return false
+	}
+	s.queued[task.url] = struct{}{}
+	s.seq++
+	task.seq = s.seq
+
+	host, ok := s.hosts[task.host]
+	if !ok {
+		host = &crawlHost{}
+		s.hosts[task.host] = host
+	}
+	heap.Push(&host.queue, task)
+	s.notify()
+	return true
+}
+
+// The first task of the hosts which may be pulled now, otherwise how long to wait for it.
+// The negative wait means the queues are empty.
+// The idle host is forgotten once its delay is passed, the next task of it is pulled at once anyway.
+func (s *crawlScheduler) next(now time.Time) (*crawlTask, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ready *crawlHost
+	wait := time.Duration(-1)
+	for name, host := range s.hosts {
+		if host.busy {
+			continue
+		}
+		if len(host.queue) == 0 {
+			if !host.next.After(now) {
+				delete(s.hosts, name)
+			}
+			continue
+		}
+		if host.next.After(now) {
+			if until := host.next.Sub(now); wait < 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		if ready == nil || host.queue[0].before(ready.queue[0]) {
+			ready = host
+		}
+	}
+	if ready == nil {
+		return nil, wait
+	}
+
+	task := heap.Pop(&ready.queue).(*crawlTask)
+	ready.busy = true
+	// Other idle worker may take the next ready host
+	s.notify()
+	return task, 0
+}
+
+// The next pull of the host is delayed from the end of the pulled task
+func (s *crawlScheduler) done(task *crawlTask, pulled bool) {
+	s.mu.Lock()
+	delete(s.queued, task.url)
+	host := s.hosts[task.host]
+	host.busy = false
+	if pulled {
+		host.next = time.Now().Add(task.delay)
+	}
+	s.notify()
+	s.mu.Unlock()
+
+	if task.finish != nil {
+		task.finish()
+	}
+}
+
+// Drop the queued tasks of the stopped processors, so they don't wait for their hosts
+func (s *crawlScheduler) dropCanceled() {
+	var dropped []*crawlTask
+	s.mu.Lock()
+	for _, host := range s.hosts {
+		queue := host.queue[:0]
+		for _, task := range host.queue {
+			if task.ctx.Err() != nil {
+				delete(s.queued, task.url)
+				dropped = append(dropped, task)
+				continue
+			}
+			queue = append(queue, task)
+		}
+		clear(host.queue[len(queue):])
+		host.queue = queue
+		heap.Init(&host.queue)
+	}
+	s.mu.Unlock()
+
+	for _, task := range dropped {
+		if task.finish != nil {
+			task.finish()
+		}
+	}
+}
+
+func (s *crawlScheduler) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		task, wait := s.next(time.Now())
+		if task != nil {
+			pulled := task.ctx.Err() == nil
+			if pulled {
+				task.pull(task.ctx)
+			}
+			s.done(task, pulled)
+			continue
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// Run the workers until the ctx is done
+func (s *crawlScheduler) run(ctx context.Context) {
+	var workers sync.WaitGroup
+	for idx := 0; idx < s.workers; idx++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.work(ctx)
+		}()
+	}
+	workers.Wait()
+}
@@ -0,0 +1,86 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCrawlSchedulerForgetsIdleHost(t *testing.T) {
+	const delay = time.Minute
+	scheduler := newCrawlScheduler(1)
+	newTask := func(url string) *crawlTask {
+		return &crawlTask{url: url, delay: delay, ctx: context.Background(), pull: func(context.Context) {}}
+	}
+	scheduler.add(newTask("https://example.com/1"))
+
+	task, _ := scheduler.next(time.Now())
+	if task == nil {
+		t.Fatal("queued task is not ready")
+	}
+	scheduler.done(task, true)
+
+	// The host keeps its delay until it's passed
+	scheduler.add(newTask("https://example.com/2"))
+	if task, wait := scheduler.next(time.Now()); task != nil || wait <= 0 {
+		t.Fatalf("got task %v and wait %s, want the delay of the host", task, wait)
+	}
+	task, _ = scheduler.next(time.Now().Add(delay))
+	if task == nil {
+		t.Fatal("task is not ready after the delay")
+	}
+	scheduler.done(task, true)
+
+	if _, wait := scheduler.next(time.Now()); wait >= 0 || len(scheduler.hosts) != 1 {
+		t.Fatalf("got wait %s and %d hosts, want the idle host until its delay", wait, len(scheduler.hosts))
+	}
+	if _, wait := scheduler.next(time.Now().Add(delay)); wait >= 0 || len(scheduler.hosts) != 0 {
+		t.Errorf("got wait %s and %d hosts, want no hosts after the delay", wait, len(scheduler.hosts))
+	}
+}
+
+func TestCrawlSchedulerDropsStoppedProcessor(t *testing.T) {
+	scheduler := newCrawlScheduler(1)
+	stopped := &NewsFeedProcessor{scheduler: scheduler}
+	running := &NewsFeedProcessor{scheduler: scheduler}
+
+	stoppedCtx, stop := context.WithCancel(context.Background())
+	// The host is delayed by the last pull, so the queued tasks wait
+	scheduler.add(&crawlTask{url: "https://example.com/0", delay: time.Hour, ctx: context.Background(), pull: func(context.Context) {}})
+	task, _ := scheduler.next(time.Now())
+	scheduler.done(task, true)
+
+	pulled := make(chan string, 2)
+	for _, queued := range []struct {
+		processor *NewsFeedProcessor
+		ctx       context.Context
+		url       string
+	}{
+		{stopped, stoppedCtx, "https://example.com/stopped"},
+		{running, context.Background(), "https://example.com/running"},
+	} {
+		url := queued.url
+		queued.processor.queuePull(queued.ctx, &crawlTask{url: url, pull: func(context.Context) { pulled <- url }})
+	}
+	// The queued url is skipped
+	running.queuePull(context.Background(), &crawlTask{url: "https://example.com/running", pull: func(context.Context) {}})
+
+	stop()
+	scheduler.dropCanceled()
+	// The stopped processor doesn't wait for the delayed host
+	stopped.pulls.Wait()
+	if scheduler.isQueued("https://example.com/stopped") {
+		t.Error("task of the stopped processor is still queued")
+	}
+
+	task, _ = scheduler.next(time.Now().Add(time.Hour))
+	if task == nil {
+		t.Fatal("task of the running processor is dropped")
+	}
+	task.pull(task.ctx)
+	scheduler.done(task, true)
+	running.pulls.Wait()
+	if url := <-pulled; url != "https://example.com/running" {
+		t.Errorf("got pull of %s, want the running processor", url)
+	}
+}
@@ -0,0 +1,342 @@
+package prebuiltemplate
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const (
+	DEFAULT_REFETCH_WINDOW = 24 * time.Hour
+	// Attempts of the seen url update when the other workers update it at once
+	SEEN_UPDATE_ATTEMPTS = 5
+)
+
+// Pulls of the article url
+type SeenURL struct {
+	URL         string    `json:"url"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastFetched time.Time `json:"last_fetched"`
+}
+
+// SeenStore keeps the pulled article urls, so they are not pulled again on each news feed refresh.
+// The store is shared by the processors of all templates.
+type SeenStore interface {
+	// The ok is false when the url is not seen yet
+	Get(url string) (seen SeenURL, ok bool, err error)
+	// Remember the pull of the url. The first pull of the seen url is kept, even when the workers pull it at once.
+	MarkFetched(url string, fetched time.Time) error
+}
+
+// Re-fetch of the pulled articles, like the view count updates of the fresh articles
+type RefetchConfig struct {
+	// How often the seen article is pulled again, zero disables the re-fetch
+	Interval Duration `json:"interval"`
+	// How long after the first pull the article is pulled again, 24h by default
+	Window Duration `json:"window"`
+}
+
+func (c RefetchConfig) window() time.Duration {
+	if c.Window <= 0 {
+		return DEFAULT_REFETCH_WINDOW
+	}
+	return time.Duration(c.Window)
+}
+
+// The seen article is pulled again when it's still fresh and its last pull is older than the interval
+func (c RefetchConfig) due(seen SeenURL, now time.Time) bool {
+	if c.Interval <= 0 {
+		return false
+	}
+	return now.Sub(seen.FirstSeen) < c.window() && now.Sub(seen.LastFetched) >= time.Duration(c.Interval)
+}
+
+// Seen urls of the single worker run, the dry run and the worker without the persistent store use it
+type memorySeenStore struct {
+	mu   sync.Mutex
+	urls map[string]SeenURL
+}
+
+func (s *memorySeenStore) Get(url string) (SeenURL, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen, ok := s.urls[url]
+	return seen, ok, nil
+}
+
+// The caller holds the mu
+func (s *memorySeenStore) markFetched(url string, fetched time.Time) SeenURL {
+	seen, ok := s.urls[url]
+	if !ok {
+		seen = SeenURL{URL: url, FirstSeen: fetched}
+	}
+	seen.LastFetched = fetched
+	s.urls[url] = seen
+	return seen
+}
+
+func (s *memorySeenStore) MarkFetched(url string, fetched time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markFetched(url, fetched)
+	return nil
+}
+
+func newMemorySeenStore() *memorySeenStore {
+	return &memorySeenStore{urls: make(map[string]SeenURL)}
+}
+
+// The seen urls file is rewritten without the expired and overwritten lines at least that often
+const SEEN_FILE_COMPACT_INTERVAL = time.Hour
+
+// FileSeenStore keeps the seen urls in the local file of the json lines.
+// Each pull is appended to the file, the last line of the url wins when the file is loaded.
+// The file is compacted on open, when it has twice more lines than urls and each SEEN_FILE_COMPACT_INTERVAL,
+// the urls which are not pulled for the max age are dropped then.
+type FileSeenStore struct {
+	memorySeenStore
+	path   string
+	file   *os.File
+	maxAge time.Duration
+	// Lines of the file and the time of its last compaction
+	lines     int
+	compacted time.Time
+}
+
+func (s *FileSeenStore) MarkFetched(url string, fetched time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(s.markFetched(url, fetched))
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.lines++
+
+	if s.lines > 2*len(s.urls) || time.Since(s.compacted) >= SEEN_FILE_COMPACT_INTERVAL {
+		if err := s.compact(); err != nil {
+			log.Printf("Unable compact seen urls file %s. Err: %s", s.path, err)
+		}
+	}
+	return nil
+}
+
+// Drop the expired urls and rewrite the file by the single line of each url.
+// The file is replaced by the rename, so the crash in the middle keeps the old one.
+func (s *FileSeenStore) compact() error {
+	now := time.Now()
+	s.compacted = now
+	for url, seen := range s.urls {
+		if s.maxAge > 0 && now.Sub(seen.LastFetched) > s.maxAge {
+			delete(s.urls, url)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// The temp file is private by default
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, seen := range s.urls {
+		data, err := json.Marshal(seen)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.lines = len(s.urls)
+	return nil
+}
+
+func (s *FileSeenStore) Close() error {
+	return s.file.Close()
+}
+
+// Load the seen urls of the file, the file is created when it's missing.
+// The urls which are not pulled for the max age are dropped, zero keeps them forever.
+func OpenSeenFile(path string, maxAge time.Duration) (*FileSeenStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &FileSeenStore{
+		memorySeenStore: memorySeenStore{urls: make(map[string]SeenURL)},
+		path:            path,
+		file:            file,
+		maxAge:          maxAge,
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var seen SeenURL
+		if err := json.Unmarshal(scanner.Bytes(), &seen); err != nil {
+			// The last line may be cut by the crash of the worker
+			log.Printf("Skip bad line %d of seen urls file %s. Err: %s", line, path, err)
+			continue
+		}
+		store.urls[seen.URL] = seen
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if err := store.compact(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// KeyValueSeenStore keeps the seen urls in the nats key value bucket, so the workers share them.
+// The key is the sha256 of the url, because the url has the characters which the key can't have.
+type KeyValueSeenStore struct {
+	kv nats.KeyValue
+}
+
+func seenURLKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *KeyValueSeenStore) Get(url string) (SeenURL, bool, error) {
+	entry, err := s.kv.Get(seenURLKey(url))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return SeenURL{}, false, nil
+	}
+	if err != nil {
+		return SeenURL{}, false, err
+	}
+
+	var seen SeenURL
+	if err := json.Unmarshal(entry.Value(), &seen); err != nil {
+		return SeenURL{}, false, err
+	}
+	return seen, true, nil
+}
+
+// The url is created only when it's missing and updated only by the revision which is read,
+// so the update of the other worker is read again instead of overwritten
+func (s *KeyValueSeenStore) MarkFetched(url string, fetched time.Time) error {
+	key := seenURLKey(url)
+
+	var err error
+	for attempt := 0; attempt < SEEN_UPDATE_ATTEMPTS; attempt++ {
+		var entry nats.KeyValueEntry
+		entry, err = s.kv.Get(key)
+		if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return err
+		}
+
+		seen := SeenURL{URL: url, FirstSeen: fetched}
+		if entry != nil {
+			if err := json.Unmarshal(entry.Value(), &seen); err != nil {
+				log.Printf("Overwrite bad seen url %s. Err: %s", url, err)
+				seen = SeenURL{URL: url, FirstSeen: fetched}
+			}
+		}
+		seen.LastFetched = fetched
+
+		var data []byte
+		if data, err = json.Marshal(seen); err != nil {
+			return err
+		}
+		if entry == nil {
+			_, err = s.kv.Create(key, data)
+		} else {
+			_, err = s.kv.Update(key, data, entry.Revision())
+		}
+		// The wrong revision of the update is reported as the existing key too
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return err
+		}
+	}
+	return err
+}
+
+func NewKeyValueSeenStore(kv nats.KeyValue) *KeyValueSeenStore {
+	return &KeyValueSeenStore{kv: kv}
+}
+
+// The unseen article url is pulled, the seen one is pulled again when its re-fetch is due.
+// The url is pulled when the store fails, the backend dedupes the article anyway.
+func (n *NewsFeedProcessor) isDue(url string) (due bool, unseen bool) {
+	// The queued url is pulled soon, so it's neither due nor unseen
+	if n.scheduler != nil && n.scheduler.isQueued(url) {
+		return false, false
+	}
+
+	seen, ok, err := n.seen.Get(url)
+	if err != nil {
+		log.Printf("Unable get seen url %s. Err: %s", url, err)
+		return true, true
+	}
+	if !ok {
+		return true, true
+	}
+	return n.config.Refetch.due(seen, time.Now()), false
+}
+
+// Article urls of the page which are pulled, without duplicates.
+// The unseen urls are not pulled yet, the refetch urls are seen and their re-fetch is due.
+func (n *NewsFeedProcessor) dueArticleURLs(urls []string) (unseen []string, refetch []string) {
+	checked := make(map[string]struct{})
+	for _, articleURL := range urls {
+		if _, ok := checked[articleURL]; ok {
+			continue
+		}
+		checked[articleURL] = struct{}{}
+
+		switch isDue, isUnseen := n.isDue(articleURL); {
+		case isUnseen:
+			unseen = append(unseen, articleURL)
+		case isDue:
+			refetch = append(refetch, articleURL)
+		}
+	}
+	return unseen, refetch
+}
+
+// Remember the pull of the article url
+func (n *NewsFeedProcessor) markSeen(url string) {
+	if err := n.seen.MarkFetched(url, time.Now()); err != nil {
+		log.Printf("Unable mark seen url %s. Err: %s", url, err)
+	}
+}
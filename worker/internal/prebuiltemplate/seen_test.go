@@ -0,0 +1,186 @@
+package prebuiltemplate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+func seenFileLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileSeenStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen.jsonl")
+	now := time.Now()
+
+	var lines []string
+	for _, seen := range []SeenURL{
+		{URL: "https://example.com/1", FirstSeen: now.Add(-time.Hour), LastFetched: now.Add(-time.Hour)},
+		{URL: "https://example.com/1", FirstSeen: now.Add(-time.Hour), LastFetched: now},
+		{URL: "https://example.com/expired", FirstSeen: now.Add(-72 * time.Hour), LastFetched: now.Add(-48 * time.Hour)},
+	} {
+		data, err := json.Marshal(seen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+	}
+	// The line cut by the crash
+	lines = append(lines, `{"url": "https://exa`)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenSeenFile(path, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if got := seenFileLines(t, path); len(got) != 1 {
+		t.Fatalf("compacted file has %d lines, want 1: %q", len(got), got)
+	}
+	if seen, ok, _ := store.Get("https://example.com/1"); !ok || !seen.LastFetched.Equal(now) {
+		t.Errorf("got %+v %t, want the last line of the url", seen, ok)
+	}
+	if _, ok, _ := store.Get("https://example.com/expired"); ok {
+		t.Error("expired url is kept")
+	}
+
+	// The pulls of the same url are compacted when the file has twice more lines than urls
+	for i := 0; i < 5; i++ {
+		if err := store.MarkFetched("https://example.com/2", now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := seenFileLines(t, path); len(got) > 4 {
+		t.Errorf("file has %d lines for 2 urls", len(got))
+	}
+
+	store.Close()
+	reopened, err := OpenSeenFile(path, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if seen, ok, _ := reopened.Get("https://example.com/2"); !ok || !seen.FirstSeen.Equal(now) || !seen.LastFetched.Equal(now.Add(4*time.Second)) {
+		t.Errorf("got %+v %t after reopen, want the first and the last pull", seen, ok)
+	}
+}
+
+type fakeKeyValueEntry struct {
+	nats.KeyValueEntry
+	value    []byte
+	revision uint64
+}
+
+func (e fakeKeyValueEntry) Value() []byte    { return e.value }
+func (e fakeKeyValueEntry) Revision() uint64 { return e.revision }
+
+// Bucket which writes by the expected revision like the jetstream
+type fakeKeyValue struct {
+	nats.KeyValue
+	mu       sync.Mutex
+	entries  map[string]fakeKeyValueEntry
+	revision uint64
+	// Called before each write, like the other worker which writes at once
+	beforeWrite func(key string)
+}
+
+func (kv *fakeKeyValue) Get(key string) (nats.KeyValueEntry, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	entry, ok := kv.entries[key]
+	if !ok {
+		return nil, nats.ErrKeyNotFound
+	}
+	return entry, nil
+}
+
+func (kv *fakeKeyValue) Create(key string, value []byte) (uint64, error) {
+	return kv.Update(key, value, 0)
+}
+
+func (kv *fakeKeyValue) Update(key string, value []byte, revision uint64) (uint64, error) {
+	if kv.beforeWrite != nil {
+		kv.beforeWrite(key)
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.entries[key].revision != revision {
+		return 0, &nats.APIError{Code: 400, ErrorCode: nats.JSErrCodeStreamWrongLastSequence, Description: "wrong last sequence"}
+	}
+	kv.revision++
+	kv.entries[key] = fakeKeyValueEntry{value: value, revision: kv.revision}
+	return kv.revision, nil
+}
+
+func TestKeyValueSeenStoreKeepsFirstPull(t *testing.T) {
+	const url = "https://example.com/1"
+	first := time.Date(2024, 9, 15, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	kv := &fakeKeyValue{entries: make(map[string]fakeKeyValueEntry)}
+	store := NewKeyValueSeenStore(kv)
+	other := NewKeyValueSeenStore(kv)
+
+	// The other worker pulls the url between the read and the write of this one
+	var overtaken bool
+	kv.beforeWrite = func(key string) {
+		if overtaken {
+			return
+		}
+		overtaken = true
+		if err := other.MarkFetched(url, first); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := store.MarkFetched(url, second); err != nil {
+		t.Fatal(err)
+	}
+
+	seen, ok, err := store.Get(url)
+	if err != nil || !ok {
+		t.Fatalf("url is not seen, err: %v", err)
+	}
+	if !seen.FirstSeen.Equal(first) || !seen.LastFetched.Equal(second) {
+		t.Errorf("got first seen %s and last fetched %s, want %s and %s", seen.FirstSeen, seen.LastFetched, first, second)
+	}
+}
+
+func TestKeyValueSeenStoreGivesUpOnConflicts(t *testing.T) {
+	kv := &fakeKeyValue{entries: make(map[string]fakeKeyValueEntry)}
+	store := NewKeyValueSeenStore(kv)
+	other := NewKeyValueSeenStore(kv)
+
+	var writes int
+	kv.beforeWrite = func(key string) {
+		writes++
+		// Each write of this worker is overtaken by the other one
+		if writes%2 == 1 {
+			if err := other.MarkFetched("https://example.com/1", time.Now()); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	err := store.MarkFetched("https://example.com/1", time.Now())
+	if !errors.Is(err, nats.ErrKeyExists) {
+		t.Errorf("got %v, want the conflict after %d attempts", err, SEEN_UPDATE_ATTEMPTS)
+	}
+}
@@ -0,0 +1,207 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/feed"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+	"github.com/romashorodok/news-tracker/worker/pkg/robots"
+	"github.com/romashorodok/news-tracker/worker/pkg/sitemap"
+)
+
+const (
+	DEFAULT_SITEMAP_MAX_SITEMAPS = 20
+	DEFAULT_SITEMAP_MAX_URLS     = 200
+)
+
+var ErrNoSitemaps = errors.New("robots.txt has no sitemaps")
+
+type SitemapConfig struct {
+	// Urls and nested sitemaps older than that are skipped. The entries without the date are never skipped.
+	MaxAge Duration `json:"max_age"`
+	// How many sitemaps are fetched on each refresh, including the nested sitemaps of the index
+	MaxSitemaps int `json:"max_sitemaps"`
+	// How many newest urls are pulled on each refresh
+	MaxURLs int `json:"max_urls"`
+	// Only the urls which match the regexp are pulled, like `/news/\d+`
+	URLPattern string `json:"url_pattern"`
+}
+
+func (c SitemapConfig) maxSitemaps() int {
+	if c.MaxSitemaps <= 0 {
+		return DEFAULT_SITEMAP_MAX_SITEMAPS
+	}
+	return c.MaxSitemaps
+}
+
+func (c SitemapConfig) maxURLs() int {
+	if c.MaxURLs <= 0 {
+		return DEFAULT_SITEMAP_MAX_URLS
+	}
+	return c.MaxURLs
+}
+
+// The entry is recent when its date is unknown or inside the MaxAge
+func (c SitemapConfig) isRecent(date time.Time) bool {
+	return c.MaxAge <= 0 || date.IsZero() || time.Since(date) <= time.Duration(c.MaxAge)
+}
+
+// Sitemaps of the news feed url. The robots.txt url is replaced by the sitemaps listed in it.
+func (n *NewsFeedProcessor) rootSitemaps(ctx context.Context, base *url.URL) ([]string, error) {
+	if base.Path != fetcher.ROBOTS_PATH {
+		return []string{base.String()}, nil
+	}
+
+	resp, err := n.getRemotePage(ctx, base.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	siteRobots, err := robots.Parse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(siteRobots.Sitemaps) == 0 {
+		return nil, ErrNoSitemaps
+	}
+
+	var sitemaps []string
+	for _, loc := range siteRobots.Sitemaps {
+		sitemaps = append(sitemaps, resolveURL(base, loc))
+	}
+	return sitemaps, nil
+}
+
+func (n *NewsFeedProcessor) getSitemap(ctx context.Context, loc string) (*sitemap.Sitemap, error) {
+	resp, err := n.getRemotePage(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+	return sitemap.Parse(resp)
+}
+
+// Newest entries go first, the entries without the date go last
+func byDateDesc[T any](date func(T) time.Time) func(a, b T) int {
+	return func(a, b T) int {
+		left, right := date(a), date(b)
+		switch {
+		case left.Equal(right):
+			return 0
+		case left.IsZero():
+			return 1
+		case right.IsZero():
+			return -1
+		case left.After(right):
+			return -1
+		}
+		return 1
+	}
+}
+
+// Collect the recent urls of the sitemaps. The sitemap index is walked from the newest nested sitemaps.
+func (n *NewsFeedProcessor) discoverSitemapURLs(ctx context.Context, base *url.URL) ([]sitemap.URL, error) {
+	config := n.config.Sitemap
+
+	var pattern *regexp.Regexp
+	if config.URLPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(config.URLPattern); err != nil {
+			return nil, err
+		}
+	}
+
+	queue, err := n.rootSitemaps(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []sitemap.URL
+	seen := make(map[string]struct{})
+	for fetched := 0; len(queue) > 0 && fetched < config.maxSitemaps(); fetched++ {
+		loc := queue[0]
+		queue = queue[1:]
+
+		log.Println("Get sitemap at", loc)
+		siteSitemap, err := n.getSitemap(ctx, loc)
+		if err != nil {
+			log.Printf("Unable get sitemap at %s. Err: %s", loc, err)
+			continue
+		}
+
+		nested := slices.Clone(siteSitemap.Sitemaps)
+		slices.SortStableFunc(nested, byDateDesc(func(entry sitemap.Entry) time.Time { return entry.LastMod }))
+		for _, entry := range nested {
+			if config.isRecent(entry.LastMod) {
+				queue = append(queue, resolveURL(base, entry.Loc))
+			}
+		}
+
+		for _, siteURL := range siteSitemap.URLs {
+			siteURL.Loc = resolveURL(base, siteURL.Loc)
+			if _, ok := seen[siteURL.Loc]; ok || !config.isRecent(siteURL.Date()) {
+				continue
+			}
+			if pattern != nil && !pattern.MatchString(siteURL.Loc) {
+				continue
+			}
+			seen[siteURL.Loc] = struct{}{}
+			urls = append(urls, siteURL)
+		}
+	}
+
+	slices.SortStableFunc(urls, byDateDesc(sitemap.URL.Date))
+	if len(urls) > config.maxURLs() {
+		urls = urls[:config.maxURLs()]
+	}
+	return urls, nil
+}
+
+// The sitemap url is the feed item which always require the article page
+func sitemapItem(siteURL sitemap.URL) feed.Item {
+	item := feed.Item{
+		URL:         siteURL.Loc,
+		PublishedAt: siteURL.Date(),
+	}
+	if siteURL.News != nil {
+		item.Title = siteURL.News.Title
+		item.Categories = siteURL.News.Keywords
+	}
+	if len(siteURL.Images) > 0 {
+		item.Image = siteURL.Images[0]
+		for _, image := range siteURL.Images[1:] {
+			item.Enclosures = append(item.Enclosures, feed.Enclosure{URL: image, Type: "image"})
+		}
+	}
+	return item
+}
+
+// Pull the article pages of the urls which are discovered by the sitemaps.
+// Unlike the news feed page the sitemaps keep the articles for days, so the articles are not missed while the worker is down.
+func (n *NewsFeedProcessor) refreshSitemaps(ctx context.Context) error {
+	base, err := url.Parse(n.config.NewsFeedURL)
+	if err != nil {
+		return err
+	}
+
+	urls, err := n.discoverSitemapURLs(ctx, base)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Found %d urls in sitemaps of %s", len(urls), n.config.NewsFeedURL)
+	for _, siteURL := range urls {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		n.onFeedItem(ctx, sitemapItem(siteURL), base, "")
+	}
+	return nil
+}
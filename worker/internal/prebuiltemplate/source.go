@@ -0,0 +1,214 @@
+package prebuiltemplate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/pkg/dateutils"
+	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/jsonld"
+	"github.com/romashorodok/news-tracker/worker/pkg/metadata"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+const (
+	// Nodes selected by the xpath, css or class selector of the field
+	FIELD_SOURCE_SELECTOR = "selector"
+	// JSON-LD `NewsArticle` of the page. The `jsonld:<property>` takes the exact property.
+	FIELD_SOURCE_JSONLD = "jsonld"
+	// OpenGraph, Twitter Card and other `<meta>` of the page. The `meta:<property>` takes the exact property.
+	// The `meta:canonical` is the `<link rel="canonical">` url.
+	FIELD_SOURCE_META = "meta"
+)
+
+var ErrUnknownFieldSource = errors.New("unknown field source")
+
+// schema.org properties of the article for each field type. They are tried in order.
+// https://schema.org/NewsArticle
+var JSONLD_PROPERTIES = map[string][]string{
+	FIELD_TYPE_TITLE:          {"headline", "name"},
+	FIELD_TYPE_PREFACE:        {"description", "alternativeHeadline"},
+	FIELD_TYPE_CONTENT:        {"articleBody", "text"},
+	FIELD_TYPE_PUBLISHED_AT:   {"datePublished", "dateCreated"},
+	FIELD_TYPE_INFO:           {"interactionStatistic.userInteractionCount"},
+	FIELD_TYPE_MAIN_IMAGE:     {"image", "image.url", "thumbnailUrl"},
+	FIELD_TYPE_CONTENT_IMAGES: {"image", "image.url"},
+	FIELD_TYPE_AUTHOR:         {"author", "author.name"},
+	FIELD_TYPE_SECTION:        {"articleSection"},
+}
+
+// `<meta>` properties for each field type. They are tried in order.
+// https://ogp.me, https://developer.x.com/en/docs/twitter-for-websites/cards/overview/markup
+var META_PROPERTIES = map[string][]string{
+	FIELD_TYPE_TITLE:          {"og:title", "twitter:title"},
+	FIELD_TYPE_PREFACE:        {"og:description", "twitter:description", "description"},
+	FIELD_TYPE_PUBLISHED_AT:   {"article:published_time", "og:article:published_time", "pubdate"},
+	FIELD_TYPE_MAIN_IMAGE:     {"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src"},
+	FIELD_TYPE_CONTENT_IMAGES: {"og:image"},
+	FIELD_TYPE_AUTHOR:         {"article:author", "author"},
+	FIELD_TYPE_SECTION:        {"article:section"},
+}
+
+// Split the `jsonld:headline` into the source name and its argument
+func parseSource(source string) (string, string) {
+	name, argument, _ := strings.Cut(source, ":")
+	return name, argument
+}
+
+func (n *ArticlePageExtractor) structuredArticle(doc *parser.Document) jsonld.Object {
+	if n.structuredParsed {
+		return n.structured
+	}
+	n.structuredParsed = true
+
+	objects, err := jsonld.Extract(doc)
+	if err != nil {
+		log.Println("Some json-ld scripts are skipped. Err:", err)
+	}
+	n.structured = jsonld.FindArticle(objects)
+	return n.structured
+}
+
+func (n *ArticlePageExtractor) pageMetadata(doc *parser.Document) metadata.Metadata {
+	if n.metadata != nil {
+		return n.metadata
+	}
+
+	pageMetadata, err := metadata.Extract(doc)
+	if err != nil {
+		log.Println("Unable collect page metadata. Err:", err)
+		pageMetadata = make(metadata.Metadata)
+	}
+	n.metadata = pageMetadata
+	return n.metadata
+}
+
+func (n *ArticlePageExtractor) extractFromSource(doc *parser.Document, field Field, source string, onField func(*parser.Node)) error {
+	name, argument := parseSource(source)
+
+	switch name {
+	case FIELD_SOURCE_SELECTOR:
+		nodes, err := fieldNodes(doc, field)
+		if err != nil {
+			return err
+		}
+		// The transformed field takes the values of the nodes instead of the nodes
+		if len(field.Transforms) > 0 {
+			var values []string
+			for _, node := range nodes {
+				values = append(values, nodeValues(field, node)...)
+			}
+			return n.setFieldValues(field, values)
+		}
+		for _, node := range nodes {
+			onField(node)
+		}
+		return nil
+
+	case FIELD_SOURCE_JSONLD:
+		article := n.structuredArticle(doc)
+		if article == nil {
+			return nil
+		}
+		properties := JSONLD_PROPERTIES[field.Type]
+		if argument != "" {
+			properties = []string{argument}
+		}
+		return n.setFieldValues(field, article.Strings(properties...))
+
+	case FIELD_SOURCE_META:
+		properties := META_PROPERTIES[field.Type]
+		if argument != "" {
+			properties = []string{argument}
+		}
+		var values []string
+		for _, property := range properties {
+			values = append(values, n.pageMetadata(doc).Values(property)...)
+		}
+		return n.setFieldValues(field, values)
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownFieldSource, source)
+}
+
+// Fill the field by the values which are not the document nodes
+func (n *ArticlePageExtractor) setFieldValues(field Field, values []string) error {
+	values, err := n.transformValues(field, values)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	first := values[0]
+
+	switch field.Type {
+	case FIELD_TYPE_TITLE:
+		n.article.Title = first
+	case FIELD_TYPE_PREFACE:
+		n.article.Preface = first
+	case FIELD_TYPE_CONTENT:
+		for _, sentence := range field.IgnoredSentences {
+			first = strings.Replace(first, sentence, "", -1)
+		}
+		n.article.Content = first
+	case FIELD_TYPE_PUBLISHED_AT:
+		for _, value := range values {
+			if date, err := dateutils.ParseISO8601(value); err == nil {
+				n.article.PublishedAt = date
+				return nil
+			}
+			// The text of the selected node
+			if date, err := dateutils.ParseDateUA(value); err == nil {
+				n.article.PublishedAt = date
+				return nil
+			}
+		}
+	case FIELD_TYPE_INFO:
+		if viewersCount, err := strconv.Atoi(first); err == nil {
+			n.article.ViewersCount = viewersCount
+		}
+	case FIELD_TYPE_MAIN_IMAGE:
+		n.article.MainImage = n.absoluteURL(first)
+	case FIELD_TYPE_CONTENT_IMAGES:
+		for _, value := range values {
+			n.article.ContentImages = append(n.article.ContentImages, n.absoluteURL(value))
+		}
+	case FIELD_TYPE_AUTHOR:
+		n.article.Authors = append(n.article.Authors, values...)
+	case FIELD_TYPE_SECTION:
+		n.article.Section = first
+	}
+	return nil
+}
+
+func (n *ArticlePageExtractor) isFieldEmpty(fieldType string) bool {
+	return articleFieldEmpty(n.article, fieldType)
+}
+
+func articleFieldEmpty(article natsinfo.Article, fieldType string) bool {
+	switch fieldType {
+	case FIELD_TYPE_TITLE:
+		return article.Title == ""
+	case FIELD_TYPE_PREFACE:
+		return article.Preface == ""
+	case FIELD_TYPE_CONTENT:
+		return article.Content == ""
+	case FIELD_TYPE_PUBLISHED_AT:
+		return article.PublishedAt.IsZero()
+	case FIELD_TYPE_INFO:
+		return article.ViewersCount == 0
+	case FIELD_TYPE_MAIN_IMAGE:
+		return article.MainImage == ""
+	case FIELD_TYPE_CONTENT_IMAGES:
+		return len(article.ContentImages) == 0
+	case FIELD_TYPE_AUTHOR:
+		return len(article.Authors) == 0
+	case FIELD_TYPE_SECTION:
+		return article.Section == ""
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/romashorodok/news-tracker/pkg/natsinfo"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
+)
+
+// Running processor of the template
+type supervisedProcessor struct {
+	config NewsFeedConfig
+	cancel context.CancelFunc
+}
+
+// Supervisor runs the processor of each template.
+// The template may be added, changed or removed at runtime, the processors of other templates are untouched.
+type Supervisor struct {
+	ctx       context.Context
+	seen      SeenStore
+	fetcher   *fetcher.Fetcher
+	scheduler *crawlScheduler
+	onArticle func(natsinfo.Article)
+
+	mu         sync.Mutex
+	processors map[string]*supervisedProcessor
+}
+
+func (s *Supervisor) start(key string, config NewsFeedConfig) *supervisedProcessor {
+	ctx, cancel := context.WithCancel(s.ctx)
+	processor := newNewsFeedProcessor(config, s.seen, s.fetcher, s.scheduler)
+	go processor.Start(ctx)
+	go func() {
+		for article := range processor.GetArticleChan() {
+			s.onArticle(article)
+		}
+		log.Printf("Stopped news feed processor of %s template", key)
+	}()
+	return &supervisedProcessor{config: config, cancel: cancel}
+}
+
+// Start the processor of the template. The running processor of the key is restarted when its template is changed.
+func (s *Supervisor) Set(key string, config NewsFeedConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if running, ok := s.processors[key]; ok {
+		if reflect.DeepEqual(running.config, config) {
+			return
+		}
+		log.Printf("Reconfigure news feed processor of %s template", key)
+		running.cancel()
+	} else {
+		log.Printf("Start news feed processor of %s template", key)
+	}
+
+	s.processors[key] = s.start(key, config)
+}
+
+// Stop the processor of the template
+func (s *Supervisor) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if running, ok := s.processors[key]; ok {
+		log.Printf("Stop news feed processor of %s template", key)
+		running.cancel()
+		delete(s.processors, key)
+	}
+}
+
+// Keys of the running templates in order
+func (s *Supervisor) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.processors))
+	for key := range s.processors {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// The processors are stopped when the ctx is done. Each article of them is passed to the onArticle.
+// The seen store is shared by the processors, they keep the seen urls in memory when it's nil.
+// The fetcher is shared too, so the templates of the same host respect its rate limit and robots.txt together.
+// The article pages of all templates are pulled by the articleWorkers of one crawl scheduler, DEFAULT_ARTICLE_WORKERS when it's not positive.
+func NewSupervisor(ctx context.Context, seen SeenStore, sharedFetcher *fetcher.Fetcher, articleWorkers int, onArticle func(natsinfo.Article)) *Supervisor {
+	if sharedFetcher == nil {
+		sharedFetcher = fetcher.New(fetcher.Config{})
+	}
+	scheduler := newCrawlScheduler(articleWorkers)
+	go scheduler.run(ctx)
+	return &Supervisor{
+		ctx:        ctx,
+		seen:       seen,
+		fetcher:    sharedFetcher,
+		scheduler:  scheduler,
+		onArticle:  onArticle,
+		processors: make(map[string]*supervisedProcessor),
+	}
+}
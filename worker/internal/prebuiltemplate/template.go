@@ -0,0 +1,173 @@
+package prebuiltemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extensions of the template files which are loaded from the directory
+var TEMPLATE_FILE_EXTENSIONS = []string{".json", ".yaml", ".yml"}
+
+var ErrUnknownTemplateFormat = errors.New("unknown template file format")
+
+// Duration of the template. It's the human duration like `30s` or `10m`, the integer is the nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var nanoseconds int64
+	if err := json.Unmarshal(data, &nanoseconds); err == nil {
+		*d = Duration(nanoseconds)
+		return nil
+	}
+
+	var human string
+	if err := json.Unmarshal(data, &human); err != nil {
+		return fmt.Errorf("duration must be the string like `30s` or the nanoseconds. Got %s", data)
+	}
+	duration, err := time.ParseDuration(human)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Decode the json template. The unknown fields are the error, like the misspelled field name.
+func decodeTemplate(data []byte) (NewsFeedConfig, error) {
+	var config NewsFeedConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// The yaml template is decoded like the json one, so the json tags describe both formats
+func yamlToJSON(data []byte) ([]byte, error) {
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
+
+// Decode the json of the single template or the list of them
+func parseTemplates(data []byte) ([]NewsFeedConfig, error) {
+	var items []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		items = []json.RawMessage{data}
+	}
+
+	var configs []NewsFeedConfig
+	for idx, item := range items {
+		config, err := decodeTemplate(item)
+		if err != nil {
+			if len(items) > 1 {
+				return nil, fmt.Errorf("template %d: %w", idx, err)
+			}
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// Templates of the json or yaml file. The file has the single template or the list of them.
+func ParseTemplateFile(path string, data []byte) ([]NewsFeedConfig, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, err
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownTemplateFormat, path)
+	}
+	return parseTemplates(data)
+}
+
+// Parse and validate the templates of the file or the bucket value
+func parseValidTemplates(name string, data []byte) ([]NewsFeedConfig, error) {
+	configs, err := ParseTemplateFile(name, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, config := range configs {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+// Template files of the directory in the name order, or the path itself when it's the file
+func templateFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || !slices.Contains(TEMPLATE_FILE_EXTENSIONS, ext) {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	return files, nil
+}
+
+// Load and validate the templates of the file or the directory
+func LoadTemplates(path string) ([]NewsFeedConfig, error) {
+	files, err := templateFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []NewsFeedConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		fileConfigs, err := parseValidTemplates(file, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		configs = append(configs, fileConfigs...)
+	}
+	return configs, nil
+}
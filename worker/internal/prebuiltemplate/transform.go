@@ -0,0 +1,153 @@
+package prebuiltemplate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+const (
+	// Replace the Pattern matches by the Replacement, the `$1` refers to the group
+	TRANSFORM_REGEX_REPLACE = "regex_replace"
+	// Take the Group of the first Pattern match, the whole match by default. The value without match is dropped.
+	TRANSFORM_REGEX_EXTRACT       = "regex_extract"
+	TRANSFORM_TRIM                = "trim"
+	TRANSFORM_COLLAPSE_WHITESPACE = "collapse_whitespace"
+	TRANSFORM_STRIP_PREFIX        = "strip_prefix"
+	TRANSFORM_STRIP_SUFFIX        = "strip_suffix"
+	// Split the value by the Value into the several values, the empty parts are dropped
+	TRANSFORM_SPLIT = "split"
+	// Keep the digits of the value, like `1 234` is `1234`. The value without digits is dropped.
+	TRANSFORM_TO_INT = "to_int"
+	// Prefix the relative url by the ArticlePrefixURL
+	TRANSFORM_TO_ABSOLUTE_URL = "to_absolute_url"
+	TRANSFORM_LOWERCASE       = "lowercase"
+)
+
+var ErrUnknownTransform = errors.New("unknown transform")
+
+// Transform of the field values. They are applied in order after the value is taken from the source.
+//
+// Example: `Переглядів: 1 234` is the viewers count 1234 by the
+// `{"type": "strip_prefix", "value": "Переглядів:"}` and `{"type": "to_int"}`
+type Transform struct {
+	Type        string `json:"type"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+	Group       int    `json:"group"`
+	// Prefix or suffix of the strip transforms and the separator of the split
+	Value string `json:"value"`
+}
+
+// The templates transform the values of each page by the same patterns, so they are compiled once
+var compiledPatterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if compiled, ok := compiledPatterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, compiled)
+	return compiled, nil
+}
+
+func toInt(value string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+	if digits == "" {
+		return "", false
+	}
+	number, err := strconv.Atoi(digits)
+	if err != nil {
+		return "", false
+	}
+	return strconv.Itoa(number), true
+}
+
+// Apply the transform to each value. The transform may drop the value or split it into several values.
+func (n *ArticlePageExtractor) applyTransform(transform Transform, values []string) ([]string, error) {
+	var pattern *regexp.Regexp
+	if transform.Type == TRANSFORM_REGEX_REPLACE || transform.Type == TRANSFORM_REGEX_EXTRACT {
+		var err error
+		if pattern, err = compilePattern(transform.Pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []string
+	for _, value := range values {
+		switch transform.Type {
+		case TRANSFORM_REGEX_REPLACE:
+			result = append(result, pattern.ReplaceAllString(value, transform.Replacement))
+		case TRANSFORM_REGEX_EXTRACT:
+			match := pattern.FindStringSubmatch(value)
+			if transform.Group < len(match) {
+				result = append(result, match[transform.Group])
+			}
+		case TRANSFORM_TRIM:
+			result = append(result, strings.TrimSpace(value))
+		case TRANSFORM_COLLAPSE_WHITESPACE:
+			result = append(result, strings.Join(strings.FieldsFunc(value, unicode.IsSpace), " "))
+		case TRANSFORM_STRIP_PREFIX:
+			result = append(result, strings.TrimPrefix(value, transform.Value))
+		case TRANSFORM_STRIP_SUFFIX:
+			result = append(result, strings.TrimSuffix(value, transform.Value))
+		case TRANSFORM_SPLIT:
+			for _, part := range strings.Split(value, transform.Value) {
+				if part = strings.TrimSpace(part); part != "" {
+					result = append(result, part)
+				}
+			}
+		case TRANSFORM_TO_INT:
+			if number, ok := toInt(value); ok {
+				result = append(result, number)
+			}
+		case TRANSFORM_TO_ABSOLUTE_URL:
+			result = append(result, n.absoluteURL(value))
+		case TRANSFORM_LOWERCASE:
+			result = append(result, strings.ToLower(value))
+		default:
+			return nil, fmt.Errorf("%w %q", ErrUnknownTransform, transform.Type)
+		}
+	}
+	return result, nil
+}
+
+func (n *ArticlePageExtractor) transformValues(field Field, values []string) ([]string, error) {
+	for _, transform := range field.Transforms {
+		var err error
+		if values, err = n.applyTransform(transform, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Value of the selected node which is transformed. The images are taken by the `src`, other fields by the text.
+func nodeValues(field Field, node *parser.Node) []string {
+	switch field.Type {
+	case FIELD_TYPE_MAIN_IMAGE, FIELD_TYPE_CONTENT_IMAGES:
+		var images []string
+		for _, img := range node.FindAll(parser.ByName("img")) {
+			if img.Attr("src") != "" {
+				images = append(images, img.Attr("src"))
+			}
+		}
+		return images
+	}
+	return []string{node.Text()}
+}
@@ -0,0 +1,209 @@
+package prebuiltemplate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/feed"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/css"
+)
+
+var (
+	FEED_TYPES = []string{feed.TYPE_HTML, feed.TYPE_RSS, feed.TYPE_ATOM, feed.TYPE_JSONFEED, feed.TYPE_SITEMAP}
+
+	FIELD_TYPES = []string{
+		FIELD_TYPE_TITLE, FIELD_TYPE_PREFACE, FIELD_TYPE_CONTENT, FIELD_TYPE_PUBLISHED_AT, FIELD_TYPE_INFO,
+		FIELD_TYPE_MAIN_IMAGE, FIELD_TYPE_CONTENT_IMAGES, FIELD_TYPE_AUTHOR, FIELD_TYPE_SECTION,
+	}
+
+	FIELD_SOURCES = []string{FIELD_SOURCE_SELECTOR, FIELD_SOURCE_JSONLD, FIELD_SOURCE_META}
+
+	TRANSFORM_TYPES = []string{
+		TRANSFORM_REGEX_REPLACE, TRANSFORM_REGEX_EXTRACT, TRANSFORM_TRIM, TRANSFORM_COLLAPSE_WHITESPACE,
+		TRANSFORM_STRIP_PREFIX, TRANSFORM_STRIP_SUFFIX, TRANSFORM_SPLIT, TRANSFORM_TO_INT,
+		TRANSFORM_TO_ABSOLUTE_URL, TRANSFORM_LOWERCASE,
+	}
+)
+
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// Problems of the template, each of them is reported with the json path of the value
+type templateProblems []error
+
+func (p *templateProblems) add(path string, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (p *templateProblems) check(path string, err error) {
+	if err != nil {
+		p.add(path, "%s", err)
+	}
+}
+
+func validateURL(problems *templateProblems, path string, value string) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		problems.check(path, err)
+		return
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		problems.add(path, "must be the absolute http or https url. Got %q", value)
+	}
+}
+
+func validateCss(problems *templateProblems, path string, query string) {
+	if query == "" {
+		return
+	}
+	_, err := css.Compile(query)
+	problems.check(path, err)
+}
+
+func validateRegexp(problems *templateProblems, path string, pattern string) {
+	_, err := regexp.Compile(pattern)
+	problems.check(path, err)
+}
+
+func (t Transform) validate(problems *templateProblems, path string) {
+	if !slices.Contains(TRANSFORM_TYPES, t.Type) {
+		problems.add(path+".type", "unknown transform %q", t.Type)
+		return
+	}
+
+	switch t.Type {
+	case TRANSFORM_REGEX_REPLACE, TRANSFORM_REGEX_EXTRACT:
+		if t.Pattern == "" {
+			problems.add(path+".pattern", "required by the %s transform", t.Type)
+			return
+		}
+		validateRegexp(problems, path+".pattern", t.Pattern)
+		if t.Type == TRANSFORM_REGEX_EXTRACT && t.Group < 0 {
+			problems.add(path+".group", "must not be negative")
+		}
+	case TRANSFORM_STRIP_PREFIX, TRANSFORM_STRIP_SUFFIX, TRANSFORM_SPLIT:
+		if t.Value == "" {
+			problems.add(path+".value", "required by the %s transform", t.Type)
+		}
+	}
+}
+
+func (f Field) validate(problems *templateProblems, path string) {
+	if !slices.Contains(FIELD_TYPES, f.Type) {
+		problems.add(path+".type", "unknown field type %q", f.Type)
+	}
+
+	validateCss(problems, path+".css_selector", f.CssSelector)
+	if f.XPath != "" {
+		_, err := compileXPath(f.XPath)
+		problems.check(path+".xpath", err)
+	}
+
+	for idx, source := range f.sources() {
+		sourcePath := path + ".source"
+		if idx > 0 {
+			sourcePath = fmt.Sprintf("%s.fallback[%d]", path, idx-1)
+		}
+
+		name, _ := parseSource(source)
+		switch {
+		case !slices.Contains(FIELD_SOURCES, name):
+			problems.add(sourcePath, "unknown field source %q", source)
+		case name == FIELD_SOURCE_SELECTOR && !f.hasSelector():
+			problems.add(sourcePath, "the selector source requires the xpath, css_selector or class_selector")
+		}
+	}
+
+	for idx, transform := range f.Transforms {
+		transform.validate(problems, fmt.Sprintf("%s.transforms[%d]", path, idx))
+	}
+}
+
+// Validate the template before the worker starts.
+// All problems are reported at once, each of them on its own line.
+func (c NewsFeedConfig) Validate() error {
+	var problems templateProblems
+
+	if c.NewsFeedURL == "" {
+		problems.add("news_feed_url", "required")
+	} else {
+		validateURL(&problems, "news_feed_url", c.NewsFeedURL)
+	}
+	if c.NewsFeedRefreshInterval <= 0 {
+		problems.add("news_feed_refresh_interval", "required, like `10m`")
+	}
+	switch {
+	case c.pullsArticlePages() && c.ArticlePullInterval <= 0:
+		problems.add("article_pull_interval", "required to pull the article pages, like `30s`")
+	case c.ArticlePullInterval < 0:
+		problems.add("article_pull_interval", "must not be negative")
+	}
+
+	if !slices.Contains(FEED_TYPES, c.feedType()) {
+		problems.add("feed_type", "unknown feed type %q, must be one of %s", c.FeedType, strings.Join(FEED_TYPES, ", "))
+	}
+
+	if c.feedType() == feed.TYPE_HTML {
+		switch {
+		case c.ArticlePageCssSelector != "":
+			validateCss(&problems, "article_page_css_selector", c.ArticlePageCssSelector)
+			validateCss(&problems, "news_feed_article_css_selector", c.NewsFeedArticleCssSelector)
+		case c.NewsFeedArticleCssSelector != "" || len(c.NewsFeedArticleSelector) > 0:
+			validateCss(&problems, "news_feed_article_css_selector", c.NewsFeedArticleCssSelector)
+			if len(c.ArticlePageSelector) == 0 {
+				problems.add("article_page_selector", "required to find the article page link inside the news feed article")
+			}
+		default:
+			problems.add("news_feed_article_css_selector", "required to find the articles of the html news feed")
+		}
+		if len(c.ArticleConfig.Fields) == 0 {
+			problems.add("article_config.fields", "required to extract the articles of the html news feed")
+		}
+	}
+
+	validateCss(&problems, "news_feed_next_page_selector", c.NewsFeedNextPageSelector)
+	if c.NewsFeedPageURLPattern != "" && !strings.Contains(c.NewsFeedPageURLPattern, PAGE_NUMBER_PLACEHOLDER) {
+		problems.add("news_feed_page_url_pattern", "must have the %s placeholder", PAGE_NUMBER_PLACEHOLDER)
+	}
+	if c.Sitemap.URLPattern != "" {
+		validateRegexp(&problems, "sitemap.url_pattern", c.Sitemap.URLPattern)
+	}
+
+	if c.HTTP.Timeout < 0 {
+		problems.add("http.timeout", "must not be negative")
+	}
+	if c.HTTP.RetryBackoff < 0 {
+		problems.add("http.retry_backoff", "must not be negative")
+	}
+	if c.HTTP.MaxRetryBackoff < 0 {
+		problems.add("http.max_retry_backoff", "must not be negative")
+	}
+	if c.HTTP.RateBurst < 0 {
+		problems.add("http.rate_burst", "must not be negative")
+	}
+	if c.HTTP.CacheTTL < 0 {
+		problems.add("http.cache_ttl", "must not be negative")
+	}
+	if c.HTTP.RobotsTTL < 0 {
+		problems.add("http.robots_ttl", "must not be negative")
+	}
+
+	if c.Refetch.Interval < 0 {
+		problems.add("refetch.interval", "must not be negative")
+	}
+	if c.Refetch.Window < 0 {
+		problems.add("refetch.window", "must not be negative")
+	}
+
+	for idx, field := range c.ArticleConfig.Fields {
+		field.validate(&problems, fmt.Sprintf("article_config.fields[%d]", idx))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w %s:\n%w", ErrInvalidTemplate, c.NewsFeedURL, errors.Join(problems...))
+}
@@ -0,0 +1,186 @@
+package prebuiltemplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+)
+
+const DEFAULT_TEMPLATES_WATCH_INTERVAL = 5 * time.Second
+
+// Templates of the sources like the files or the bucket keys. Each source may have several templates.
+type templateSources struct {
+	supervisor *Supervisor
+	keys       map[string][]string
+}
+
+func newTemplateSources(supervisor *Supervisor) *templateSources {
+	return &templateSources{
+		supervisor: supervisor,
+		keys:       make(map[string][]string),
+	}
+}
+
+// Set the templates of the source, its templates which are gone are removed
+func (t *templateSources) apply(source string, configs []NewsFeedConfig) {
+	var keys []string
+	for idx, config := range configs {
+		key := source
+		if len(configs) > 1 {
+			key = fmt.Sprintf("%s#%d", source, idx)
+		}
+		keys = append(keys, key)
+		t.supervisor.Set(key, config)
+	}
+
+	for _, key := range t.keys[source] {
+		if !slices.Contains(keys, key) {
+			t.supervisor.Remove(key)
+		}
+	}
+	t.keys[source] = keys
+}
+
+func (t *templateSources) remove(source string) {
+	for _, key := range t.keys[source] {
+		t.supervisor.Remove(key)
+	}
+	delete(t.keys, source)
+}
+
+type templateFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Templates directory which is scanned for the changed files
+type templatesDir struct {
+	path    string
+	sources *templateSources
+	states  map[string]templateFileState
+}
+
+func (d *templatesDir) scan() {
+	files, err := templateFiles(d.path)
+	// The removed directory has no templates, other errors keep the running templates
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Unable list templates at %s. Err: %s", d.path, err)
+		return
+	}
+
+	current := make(map[string]struct{})
+	for _, file := range files {
+		current[file] = struct{}{}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		state := templateFileState{modTime: info.ModTime(), size: info.Size()}
+		if previous, ok := d.states[file]; ok && previous == state {
+			continue
+		}
+		d.states[file] = state
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("Unable read template %s. Err: %s", file, err)
+			continue
+		}
+		configs, err := parseValidTemplates(file, data)
+		if err != nil {
+			log.Printf("Keep the running templates of %s. Err: %s", file, err)
+			continue
+		}
+		d.sources.apply(file, configs)
+	}
+
+	for file := range d.states {
+		if _, ok := current[file]; !ok {
+			delete(d.states, file)
+			d.sources.remove(file)
+		}
+	}
+}
+
+// Watch the templates directory and apply the changed files to the supervisor.
+// The invalid file is reported and its running templates are kept until the file is fixed.
+func WatchTemplatesDir(ctx context.Context, dir string, interval time.Duration, supervisor *Supervisor) {
+	if interval <= 0 {
+		interval = DEFAULT_TEMPLATES_WATCH_INTERVAL
+	}
+	templates := &templatesDir{
+		path:    dir,
+		sources: newTemplateSources(supervisor),
+		states:  make(map[string]templateFileState),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		templates.scan()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The bucket value is json unless the key has the yaml extension
+func bucketTemplateName(key string) string {
+	switch strings.ToLower(filepath.Ext(key)) {
+	case ".yaml", ".yml", ".json":
+		return key
+	}
+	return key + ".json"
+}
+
+// Watch the templates of the key value bucket and apply them to the supervisor.
+// The key is the template name and the value is the json or yaml template, the deleted key stops its templates.
+func WatchTemplatesBucket(ctx context.Context, kv nats.KeyValue, supervisor *Supervisor) error {
+	watcher, err := kv.WatchAll(nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	sources := newTemplateSources(supervisor)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry, ok := <-watcher.Updates():
+			if !ok {
+				return nil
+			}
+			// The nil entry marks the end of the initial values
+			if entry == nil {
+				continue
+			}
+
+			switch entry.Operation() {
+			case nats.KeyValueDelete, nats.KeyValuePurge:
+				sources.remove(entry.Key())
+				continue
+			}
+
+			configs, err := parseValidTemplates(bucketTemplateName(entry.Key()), entry.Value())
+			if err != nil {
+				log.Printf("Keep the running templates of %s key. Err: %s", entry.Key(), err)
+				continue
+			}
+			sources.apply(entry.Key(), configs)
+		}
+	}
+}
@@ -3,18 +3,33 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
 	nats "github.com/nats-io/nats.go"
-	"github.com/romashorodok/news-tracker/pkg/executils"
 	"github.com/romashorodok/news-tracker/pkg/natsinfo"
 	"github.com/romashorodok/news-tracker/worker/internal/prebuiltemplate"
+	"github.com/romashorodok/news-tracker/worker/pkg/fetcher"
 	"go.uber.org/fx"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var command func([]string) error
+		switch os.Args[1] {
+		case TEST_TEMPLATE_COMMAND:
+			command = testTemplate
+		}
+		if command != nil {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	<-fx.New(
 		fx.Provide(
 			natsinfo.NewNatsConfig,
@@ -29,10 +44,15 @@ func main() {
 			// 30 * minute - 1800000000000
 
 			var prebuiltemplateConfig prebuiltemplate.ConfigFlag
-			flag.Var(&prebuiltemplateConfig, "template", "Enter config for parsing the source")
+			flag.Var(&prebuiltemplateConfig, "template", "Enter config for parsing the source. The json or the path to the json, yaml file or directory of them")
+			templatesDir := flag.String("templates-dir", "", "Watch the directory of the templates and reload the changed ones")
+			templatesBucket := flag.String("templates-bucket", "", "Watch the nats key value bucket of the templates and reload the changed ones")
+			seenBucket := flag.String("seen-bucket", natsinfo.SEEN_URLS_BUCKET_NAME, "Keep the pulled article urls in the nats key value bucket")
+			seenFile := flag.String("seen-file", "", "Keep the pulled article urls in the local file instead of the nats key value bucket")
+			articleWorkers := flag.Int("article-workers", prebuiltemplate.DEFAULT_ARTICLE_WORKERS, "Workers which pull the article pages of all templates, the pages of different hosts at once")
 			flag.Parse()
-			if len(prebuiltemplateConfig) == 0 {
-				panic("Enter config for parsing the source by `-template` flag")
+			if len(prebuiltemplateConfig) == 0 && *templatesDir == "" && *templatesBucket == "" {
+				panic("Enter config for parsing the source by `-template`, `-templates-dir` or `-templates-bucket` flag")
 			}
 
 			log.Printf("Running with the config: %+v", prebuiltemplateConfig)
@@ -42,28 +62,61 @@ func main() {
 				os.Exit(1)
 			}
 
-			executils.BatchExec(prebuiltemplateConfig,
-				func(config prebuiltemplate.NewsFeedConfig) {
-					newsFeed := prebuiltemplate.NewNewsFeedProcessor(config)
-					go newsFeed.Start(context.Background())
+			var seen prebuiltemplate.SeenStore
+			if *seenFile != "" {
+				// The file keeps the urls as long as the key value bucket
+				store, err := prebuiltemplate.OpenSeenFile(*seenFile, natsinfo.SEEN_URLS_KEY_VALUE_CONFIG.TTL)
+				if err != nil {
+					log.Panicf("unable open seen urls file %s. Err:%s", *seenFile, err)
+				}
+				seen = store
+			} else {
+				bucketConfig := natsinfo.SEEN_URLS_KEY_VALUE_CONFIG
+				bucketConfig.Bucket = *seenBucket
+				kv, err := natsinfo.CreateOrAttachKeyValue(js, &bucketConfig)
+				if err != nil {
+					log.Panicf("unable set-up nats %s key value. Err:%s", bucketConfig.Bucket, err)
+				}
+				seen = prebuiltemplate.NewKeyValueSeenStore(kv)
+			}
+
+			supervisor := prebuiltemplate.NewSupervisor(context.Background(), seen, fetcher.New(fetcher.Config{}), *articleWorkers, func(article natsinfo.Article) {
+				origin := strings.ReplaceAll(article.Origin, ".", "_")
+				subject := natsinfo.ArticlesStream_NewArticleSubject(origin, article.Title)
 
-					for article := range newsFeed.GetArticleChan() {
+				payload, err := article.Marshal()
+				if err != nil {
+					log.Printf("Feiled serialize article. Err: %s", err)
+					log.Printf("%+v", article)
+					return
+				}
 
-						origin := strings.ReplaceAll(article.Origin, ".", "_")
-						subject := natsinfo.ArticlesStream_NewArticleSubject(origin, article.Title)
+				result, err := js.Publish(subject, payload)
+				log.Printf("Publish into nats %+v %+v", result, err)
+				log.Printf("%+v", article)
+			})
 
-						payload, err := article.Marshal()
-						if err != nil {
-							log.Printf("Feiled serialize article. Err: %s", err)
-							log.Printf("%+v", article)
-							continue
-						}
+			for idx, config := range prebuiltemplateConfig {
+				supervisor.Set(fmt.Sprintf("template#%d", idx), config)
+			}
+
+			if *templatesDir != "" {
+				go prebuiltemplate.WatchTemplatesDir(context.Background(), *templatesDir, prebuiltemplate.DEFAULT_TEMPLATES_WATCH_INTERVAL, supervisor)
+			}
 
-						result, err := js.Publish(subject, payload)
-						log.Printf("Publish into nats %+v %+v", result, err)
-						log.Printf("%+v", article)
+			if *templatesBucket != "" {
+				bucketConfig := natsinfo.TEMPLATES_KEY_VALUE_CONFIG
+				bucketConfig.Bucket = *templatesBucket
+				kv, err := natsinfo.CreateOrAttachKeyValue(js, &bucketConfig)
+				if err != nil {
+					log.Panicf("unable set-up nats %s key value. Err:%s", bucketConfig.Bucket, err)
+				}
+				go func() {
+					if err := prebuiltemplate.WatchTemplatesBucket(context.Background(), kv, supervisor); err != nil {
+						log.Printf("Unable watch templates of %s bucket. Err: %s", bucketConfig.Bucket, err)
 					}
-				})
+				}()
+			}
 		}),
 	).Wait()
 }
@@ -0,0 +1,221 @@
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
+)
+
+const (
+	UTF_8        = "utf-8"
+	UTF_16LE     = "utf-16le"
+	UTF_16BE     = "utf-16be"
+	WINDOWS_1251 = "windows-1251"
+	WINDOWS_1252 = "windows-1252"
+	KOI8_R       = "koi8-r"
+	KOI8_U       = "koi8-u"
+)
+
+var ErrUnsupportedCharset = errors.New("unsupported charset")
+
+// How many bytes of the document are scanned for the `<meta charset>`
+const PRESCAN_SIZE = 1024
+
+// Encoding labels to the canonical names
+// https://encoding.spec.whatwg.org/#names-and-labels
+var LABELS = map[string]string{
+	"utf-8":             UTF_8,
+	"utf8":              UTF_8,
+	"unicode-1-1-utf-8": UTF_8,
+	"unicode11utf8":     UTF_8,
+	"unicode20utf8":     UTF_8,
+	"x-unicode20utf8":   UTF_8,
+	"utf-16le":          UTF_16LE,
+	"utf-16":            UTF_16LE,
+	"ucs-2":             UTF_16LE,
+	"unicode":           UTF_16LE,
+	"utf-16be":          UTF_16BE,
+	"unicodefffe":       UTF_16BE,
+	"windows-1251":      WINDOWS_1251,
+	"cp1251":            WINDOWS_1251,
+	"x-cp1251":          WINDOWS_1251,
+	"windows-1252":      WINDOWS_1252,
+	"cp1252":            WINDOWS_1252,
+	"x-cp1252":          WINDOWS_1252,
+	"iso-8859-1":        WINDOWS_1252,
+	"iso8859-1":         WINDOWS_1252,
+	"iso_8859-1":        WINDOWS_1252,
+	"latin1":            WINDOWS_1252,
+	"l1":                WINDOWS_1252,
+	"us-ascii":          WINDOWS_1252,
+	"ascii":             WINDOWS_1252,
+	"koi8-r":            KOI8_R,
+	"koi8_r":            KOI8_R,
+	"koi8":              KOI8_R,
+	"koi":               KOI8_R,
+	"cskoi8r":           KOI8_R,
+	"koi8-u":            KOI8_U,
+	"koi8-ru":           KOI8_U,
+}
+
+var SINGLE_BYTE_TABLES = map[string]*[128]rune{
+	WINDOWS_1251: &windows1251,
+	WINDOWS_1252: &windows1252,
+	KOI8_R:       &koi8r,
+	KOI8_U:       &koi8u,
+}
+
+// Lookup the canonical encoding name by the label. Returns empty string for unknown labels.
+func Lookup(label string) string {
+	return LABELS[strings.ToLower(strings.Trim(label, " \t\n\r\f\"'"))]
+}
+
+func detectBOM(head []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF_8, 3
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return UTF_16LE, 2
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return UTF_16BE, 2
+	}
+	return "", 0
+}
+
+func fromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return Lookup(params["charset"])
+}
+
+// Find the `<meta charset="...">` or `<meta http-equiv="Content-Type" content="text/html; charset=...">`
+func fromMeta(head []byte) string {
+	tok := parser.NewTokenizer(bytes.NewReader(head))
+	tok.RawEntities = true
+
+	for {
+		t := tok.Next()
+		if tok.Err != nil {
+			return ""
+		}
+
+		tag, ok := t.(token.OpenTag)
+		if !ok || !strings.EqualFold(tag.Name, "meta") {
+			continue
+		}
+
+		if name := Lookup(tag.Attr["charset"]); name != "" {
+			return metaEncoding(name)
+		}
+
+		if strings.EqualFold(tag.Attr["http-equiv"], "content-type") {
+			if name := fromContentType(tag.Attr["content"]); name != "" {
+				return metaEncoding(name)
+			}
+		}
+	}
+}
+
+// Find the `<?xml version="1.0" encoding="..."?>` of the feeds and other xml documents
+func fromXMLDeclaration(head []byte) string {
+	if !bytes.HasPrefix(head, []byte("<?xml")) {
+		return ""
+	}
+	end := bytes.Index(head, []byte("?>"))
+	if end == -1 {
+		return ""
+	}
+
+	declaration := string(head[:end])
+	idx := strings.Index(declaration, "encoding")
+	if idx == -1 {
+		return ""
+	}
+	value := strings.TrimLeft(declaration[idx+len("encoding"):], " \t\r\n=")
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		return ""
+	}
+	value, _, _ = strings.Cut(value[1:], value[:1])
+	return metaEncoding(Lookup(value))
+}
+
+// The document which declare the utf-16 in itself is readable as ascii, so it's not utf-16
+func metaEncoding(name string) string {
+	if name == UTF_16LE || name == UTF_16BE {
+		return UTF_8
+	}
+	return name
+}
+
+// Detect the document encoding by the BOM, Content-Type header, xml declaration or `<meta>` of the document head.
+// Returns the canonical encoding name and the length of the BOM.
+func Detect(contentType string, head []byte) (string, int) {
+	if name, bomLen := detectBOM(head); name != "" {
+		return name, bomLen
+	}
+	if name := fromContentType(contentType); name != "" {
+		return name, 0
+	}
+	if len(head) > PRESCAN_SIZE {
+		head = head[:PRESCAN_SIZE]
+	}
+	if name := fromXMLDeclaration(head); name != "" {
+		return name, 0
+	}
+	if name := fromMeta(head); name != "" {
+		return name, 0
+	}
+	return UTF_8, 0
+}
+
+// Wrap the document reader into the reader which transcode it to the utf-8.
+// Returns the detected encoding name.
+func NewReader(source io.Reader, contentType string) (io.Reader, string, error) {
+	buffered := bufio.NewReaderSize(source, PRESCAN_SIZE)
+
+	head, err := buffered.Peek(PRESCAN_SIZE)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	name, bomLen := Detect(contentType, head)
+	if _, err := buffered.Discard(bomLen); err != nil {
+		return nil, "", err
+	}
+
+	decoder, err := NewDecoder(buffered, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return decoder, name, nil
+}
+
+// Wrap the reader of the text in the encoding with the canonical name into the utf-8 reader
+func NewDecoder(source io.Reader, name string) (io.Reader, error) {
+	if table, ok := SINGLE_BYTE_TABLES[name]; ok {
+		return &singleByteReader{source: source, table: table}, nil
+	}
+
+	switch name {
+	case UTF_8:
+		return source, nil
+	case UTF_16LE:
+		return &utf16Reader{source: source, bigEndian: false}, nil
+	case UTF_16BE:
+		return &utf16Reader{source: source, bigEndian: true}, nil
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnsupportedCharset, name)
+}
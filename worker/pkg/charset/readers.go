@@ -0,0 +1,120 @@
+package charset
+
+import (
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Transcode the single-byte encoding into the utf-8
+type singleByteReader struct {
+	source io.Reader
+	table  *[128]rune
+	buf    []byte
+	// Encoded runes which not fit into the previous read
+	pending []byte
+}
+
+func (r *singleByteReader) Read(p []byte) (int, error) {
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	if n > 0 {
+		return n, nil
+	}
+
+	// Each byte takes up to 3 bytes in utf-8
+	size := len(p)/utf8.UTFMax + 1
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+
+	read, err := r.source.Read(r.buf[:size])
+
+	var encoded []byte
+	for _, b := range r.buf[:read] {
+		if b < utf8.RuneSelf {
+			encoded = append(encoded, b)
+			continue
+		}
+		encoded = utf8.AppendRune(encoded, r.table[b-0x80])
+	}
+
+	n = copy(p, encoded)
+	r.pending = encoded[n:]
+	if len(r.pending) > 0 {
+		return n, nil
+	}
+	return n, err
+}
+
+// Transcode the utf-16 into the utf-8
+type utf16Reader struct {
+	source    io.Reader
+	bigEndian bool
+	buf       []byte
+	// Bytes of the code unit or surrogate pair which split between reads
+	tail    []byte
+	pending []byte
+	err     error
+}
+
+func (r *utf16Reader) unit(b []byte) uint16 {
+	if r.bigEndian {
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	return uint16(b[1])<<8 | uint16(b[0])
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		size := len(p) + 4
+		if cap(r.buf) < size {
+			r.buf = make([]byte, size)
+		}
+
+		var read int
+		read, r.err = r.source.Read(r.buf[:size])
+		data := append(r.tail, r.buf[:read]...)
+
+		var encoded []byte
+		idx := 0
+		for ; idx+1 < len(data); idx += 2 {
+			unit := r.unit(data[idx:])
+			if !utf16.IsSurrogate(rune(unit)) {
+				encoded = utf8.AppendRune(encoded, rune(unit))
+				continue
+			}
+
+			if idx+3 >= len(data) {
+				// Wait for the second half of the surrogate pair
+				if r.err == nil {
+					break
+				}
+				encoded = utf8.AppendRune(encoded, utf8.RuneError)
+				continue
+			}
+
+			// Unpaired surrogate is replaced and the next unit is decoded on its own
+			decoded := utf16.DecodeRune(rune(unit), rune(r.unit(data[idx+2:])))
+			encoded = utf8.AppendRune(encoded, decoded)
+			if decoded != utf8.RuneError {
+				idx += 2
+			}
+		}
+
+		r.tail = append([]byte(nil), data[idx:]...)
+		if r.err != nil && len(r.tail) > 0 {
+			encoded = utf8.AppendRune(encoded, utf8.RuneError)
+			r.tail = nil
+		}
+		r.pending = encoded
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,116 @@
+package feed
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/pkg/dateutils"
+)
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Body  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// The xhtml text is the markup inside the element, the others are the escaped text
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Body)
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// https://www.rfc-editor.org/rfc/rfc4287
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      atomText       `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Summary    atomText       `xml:"summary"`
+	Content    atomText       `xml:"http://www.w3.org/2005/Atom content"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	mediaFields
+}
+
+func (a atomEntry) item() Item {
+	item := Item{
+		ID:      strings.TrimSpace(a.ID),
+		Title:   a.Title.String(),
+		Summary: a.Summary.String(),
+		Content: a.Content.String(),
+		Image:   a.image(),
+	}
+
+	for _, link := range a.Links {
+		href := strings.TrimSpace(link.Href)
+		switch link.Rel {
+		case "", "alternate":
+			if item.URL == "" {
+				item.URL = href
+			}
+		case "enclosure":
+			length, _ := strconv.ParseInt(link.Length, 10, 64)
+			item.Enclosures = append(item.Enclosures, Enclosure{URL: href, Type: link.Type, Length: length})
+		}
+	}
+	item.Enclosures = append(item.Enclosures, a.enclosures()...)
+
+	item.UpdatedAt, _ = dateutils.ParseISO8601(a.Updated)
+	item.PublishedAt, _ = dateutils.ParseISO8601(a.Published)
+	// The published date is optional in the atom
+	if item.PublishedAt.IsZero() {
+		item.PublishedAt = item.UpdatedAt
+	}
+
+	for _, author := range a.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			item.Authors = append(item.Authors, name)
+		}
+	}
+	for _, category := range a.Categories {
+		if name := firstNotEmpty(category.Label, category.Term); name != "" {
+			item.Categories = append(item.Categories, name)
+		}
+	}
+
+	return item
+}
+
+type atomFeed struct {
+	Title   atomText    `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func (a atomFeed) feed() *Feed {
+	feed := &Feed{Title: a.Title.String()}
+	for _, link := range a.Links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			feed.URL = strings.TrimSpace(link.Href)
+			break
+		}
+	}
+	for _, entry := range a.Entries {
+		feed.Items = append(feed.Items, entry.item())
+	}
+	return feed
+}
@@ -0,0 +1,59 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+func newXMLDecoder(source io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(source)
+	// Feeds are often not the strict xml, like the html entities in the titles.
+	// The html auto close is not used, the `<link>` of the rss is not void.
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	// The source is already utf-8, the declared encoding is the encoding of the original document
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// Parse the RSS 2.0 or RSS 1.0 feed
+func ParseRSS(source io.Reader) (*Feed, error) {
+	var document rssDocument
+	if err := newXMLDecoder(source).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFeed, err)
+	}
+	return document.feed(), nil
+}
+
+func ParseAtom(source io.Reader) (*Feed, error) {
+	var document atomFeed
+	if err := newXMLDecoder(source).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFeed, err)
+	}
+	return document.feed(), nil
+}
+
+func ParseJSONFeed(source io.Reader) (*Feed, error) {
+	var document jsonFeed
+	if err := json.NewDecoder(source).Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFeed, err)
+	}
+	return document.feed(), nil
+}
+
+// Parse the feed of the type. The source must be utf-8, like the reader of the charset.NewReader.
+func Parse(source io.Reader, feedType string) (*Feed, error) {
+	switch feedType {
+	case TYPE_RSS:
+		return ParseRSS(source)
+	case TYPE_ATOM:
+		return ParseAtom(source)
+	case TYPE_JSONFEED:
+		return ParseJSONFeed(source)
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownFeedType, feedType)
+}
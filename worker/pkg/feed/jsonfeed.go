@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/pkg/dateutils"
+)
+
+// https://www.jsonfeed.org/version/1.1/
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+type jsonFeedItem struct {
+	// The id must be the string, but some feeds use numbers
+	ID            json.RawMessage      `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	Image         string               `json:"image"`
+	BannerImage   string               `json:"banner_image"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *jsonFeedAuthor      `json:"author"`
+	Authors       []jsonFeedAuthor     `json:"authors"`
+	Tags          []string             `json:"tags"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+func (j jsonFeedItem) id() string {
+	var id string
+	if err := json.Unmarshal(j.ID, &id); err == nil {
+		return id
+	}
+	return strings.Trim(string(j.ID), `"`)
+}
+
+func (j jsonFeedItem) item() Item {
+	item := Item{
+		ID:      j.id(),
+		URL:     firstNotEmpty(j.URL, j.ExternalURL),
+		Title:   strings.TrimSpace(j.Title),
+		Summary: strings.TrimSpace(j.Summary),
+		Content: firstNotEmpty(j.ContentHTML, j.ContentText),
+		Image:   firstNotEmpty(j.Image, j.BannerImage),
+	}
+
+	item.PublishedAt, _ = dateutils.ParseISO8601(j.DatePublished)
+	item.UpdatedAt, _ = dateutils.ParseISO8601(j.DateModified)
+
+	// The version 1.0 has the single author
+	authors := j.Authors
+	if j.Author != nil {
+		authors = append(authors, *j.Author)
+	}
+	for _, author := range authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			item.Authors = append(item.Authors, name)
+		}
+	}
+
+	item.Categories = j.Tags
+	for _, attachment := range j.Attachments {
+		item.Enclosures = append(item.Enclosures, Enclosure{URL: attachment.URL, Type: attachment.MimeType, Length: attachment.SizeInBytes})
+	}
+
+	return item
+}
+
+type jsonFeed struct {
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+func (j jsonFeed) feed() *Feed {
+	feed := &Feed{Title: j.Title, URL: j.HomePageURL}
+	for _, item := range j.Items {
+		feed.Items = append(feed.Items, item.item())
+	}
+	return feed
+}
@@ -0,0 +1,140 @@
+package feed
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/pkg/dateutils"
+)
+
+type mediaContent struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Medium string `xml:"medium,attr"`
+	Length string `xml:"fileSize,attr"`
+}
+
+func (m mediaContent) enclosure() Enclosure {
+	enclosure := Enclosure{URL: strings.TrimSpace(m.URL), Type: m.Type}
+	if enclosure.Type == "" && m.Medium != "" {
+		enclosure.Type = m.Medium
+	}
+	enclosure.Length, _ = strconv.ParseInt(m.Length, 10, 64)
+	return enclosure
+}
+
+// Media RSS is used by the most feeds for the images
+// https://www.rssboard.org/media-rss
+type mediaFields struct {
+	MediaContents   []mediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups     []mediaContent `xml:"http://search.yahoo.com/mrss/ group>content"`
+	MediaThumbnails []mediaContent `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+func (m mediaFields) image() string {
+	for _, thumbnail := range m.MediaThumbnails {
+		if thumbnail.URL != "" {
+			return strings.TrimSpace(thumbnail.URL)
+		}
+	}
+	return ""
+}
+
+func (m mediaFields) enclosures() []Enclosure {
+	var enclosures []Enclosure
+	for _, content := range append(m.MediaContents, m.MediaGroups...) {
+		if content.URL != "" {
+			enclosures = append(enclosures, content.enclosure())
+		}
+	}
+	return enclosures
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type rssItem struct {
+	Title string `xml:"title"`
+	// The `atom:link` of the item has the same local name, so all of them are collected
+	Links       []string `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	// `content:encoded`
+	Encoded string `xml:"encoded"`
+	PubDate string `xml:"pubDate"`
+	// `dc:date` of the RSS 1.0
+	Date       string         `xml:"date"`
+	Authors    []string       `xml:"author"`
+	Creators   []string       `xml:"creator"`
+	Categories []string       `xml:"category"`
+	Enclosures []rssEnclosure `xml:"enclosure"`
+	mediaFields
+}
+
+func (r rssItem) item() Item {
+	item := Item{
+		ID:      strings.TrimSpace(r.GUID),
+		URL:     firstNotEmpty(r.Links...),
+		Title:   strings.TrimSpace(r.Title),
+		Summary: strings.TrimSpace(r.Description),
+		Content: strings.TrimSpace(r.Encoded),
+		Image:   r.image(),
+	}
+
+	// Not permanent guid may be not the url, the link is preferred
+	if item.URL == "" && strings.HasPrefix(item.ID, "http") {
+		item.URL = item.ID
+	}
+
+	if date := firstNotEmpty(r.PubDate, r.Date); date != "" {
+		item.PublishedAt, _ = dateutils.ParseRFC822(date)
+	}
+
+	for _, author := range append(r.Authors, r.Creators...) {
+		if author = strings.TrimSpace(author); author != "" {
+			item.Authors = append(item.Authors, author)
+		}
+	}
+	for _, category := range r.Categories {
+		if category = strings.TrimSpace(category); category != "" {
+			item.Categories = append(item.Categories, category)
+		}
+	}
+
+	for _, enclosure := range r.Enclosures {
+		if enclosure.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(enclosure.Length, 10, 64)
+		item.Enclosures = append(item.Enclosures, Enclosure{URL: strings.TrimSpace(enclosure.URL), Type: enclosure.Type, Length: length})
+	}
+	item.Enclosures = append(item.Enclosures, r.enclosures()...)
+
+	return item
+}
+
+type rssChannel struct {
+	Title string    `xml:"title"`
+	Links []string  `xml:"link"`
+	Items []rssItem `xml:"item"`
+}
+
+// RSS 2.0 keeps the items inside the channel, the RSS 1.0 next to it
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+	Items   []rssItem  `xml:"item"`
+}
+
+func (r rssDocument) feed() *Feed {
+	feed := &Feed{
+		Title: strings.TrimSpace(r.Channel.Title),
+		URL:   firstNotEmpty(r.Channel.Links...),
+	}
+	for _, item := range append(r.Channel.Items, r.Items...) {
+		feed.Items = append(feed.Items, item.item())
+	}
+	return feed
+}
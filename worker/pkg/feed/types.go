@@ -0,0 +1,87 @@
+package feed
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	// The html page scraped by the selectors, it's not parsed by this package
+	TYPE_HTML     = "html"
+	TYPE_RSS      = "rss"
+	TYPE_ATOM     = "atom"
+	TYPE_JSONFEED = "jsonfeed"
+	// The sitemap or the robots.txt with the sitemaps, it's parsed by the sitemap package
+	TYPE_SITEMAP = "sitemap"
+)
+
+var (
+	ErrUnknownFeedType = errors.New("unknown feed type")
+	ErrInvalidFeed     = errors.New("invalid feed")
+)
+
+// Enclosure is the file attached to the item, like the image or the podcast episode
+type Enclosure struct {
+	URL  string
+	Type string
+	// Size in bytes, zero when it's unknown
+	Length int64
+}
+
+func (e Enclosure) IsImage() bool {
+	return strings.HasPrefix(e.Type, "image")
+}
+
+// Item is the article of the feed. The Summary and Content may be the html.
+type Item struct {
+	ID          string
+	URL         string
+	Title       string
+	Summary     string
+	Content     string
+	PublishedAt time.Time
+	UpdatedAt   time.Time
+	Authors     []string
+	Categories  []string
+	// Preview image of the item when the feed has it apart from the enclosures
+	Image      string
+	Enclosures []Enclosure
+}
+
+// Preview image followed by the image enclosures without duplicates
+func (i Item) Images() []string {
+	var images []string
+	seen := make(map[string]struct{})
+	add := func(image string) {
+		if _, ok := seen[image]; ok || image == "" {
+			return
+		}
+		seen[image] = struct{}{}
+		images = append(images, image)
+	}
+
+	add(i.Image)
+	for _, enclosure := range i.Enclosures {
+		if enclosure.IsImage() {
+			add(enclosure.URL)
+		}
+	}
+	return images
+}
+
+type Feed struct {
+	Title string
+	// Site of the feed
+	URL   string
+	Items []Item
+}
+
+func firstNotEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
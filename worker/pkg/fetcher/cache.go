@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Metadata of the cached response, its body is stored next to it
+type cachedResponse struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// Paths of the metadata and the body of the url
+func (f *Fetcher) cachePaths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	key := filepath.Join(f.config.CacheDir, hex.EncodeToString(sum[:]))
+	return key + ".json", key + ".body"
+}
+
+// Response of the url from the disk cache when it's present and not expired
+func (f *Fetcher) cached(req *http.Request) (*http.Response, bool) {
+	metaPath, bodyPath := f.cachePaths(req.URL.String())
+
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, false
+	}
+	var meta cachedResponse
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, false
+	}
+	if f.config.CacheTTL > 0 && time.Since(meta.StoredAt) > f.config.CacheTTL {
+		return nil, false
+	}
+
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, false
+	}
+	return &http.Response{
+		Status:        http.StatusText(meta.StatusCode),
+		StatusCode:    meta.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        meta.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, true
+}
+
+// Body which is written to the cache while it's read. The cache entry is stored when the body is read to the end.
+type cacheBody struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (f *Fetcher) storeCached(resp *http.Response) error {
+	if err := os.MkdirAll(f.config.CacheDir, 0755); err != nil {
+		return err
+	}
+	metaPath, bodyPath := f.cachePaths(resp.Request.URL.String())
+
+	file, err := os.CreateTemp(f.config.CacheDir, filepath.Base(bodyPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	meta := cachedResponse{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		StoredAt:   time.Now(),
+	}
+
+	body := &cacheBody{ReadCloser: resp.Body, file: file}
+	resp.Body = &completeBody{ReadCloser: body, done: func() {
+		if err := body.store(metaPath, bodyPath, meta); err != nil {
+			log.Printf("Unable cache response of %s. Err: %s", meta.URL, err)
+		}
+	}}
+	return nil
+}
+
+func (b *cacheBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.file != nil {
+		if _, writeErr := b.file.Write(p[:n]); writeErr != nil {
+			b.discard()
+		}
+	}
+	return n, err
+}
+
+func (b *cacheBody) store(metaPath, bodyPath string, meta cachedResponse) error {
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), bodyPath); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, data, 0644)
+}
+
+// Drop the partial body
+func (b *cacheBody) discard() {
+	if b.file == nil {
+		return
+	}
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
+
+func (b *cacheBody) Close() error {
+	b.discard()
+	return b.ReadCloser.Close()
+}
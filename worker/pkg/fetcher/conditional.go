@@ -0,0 +1,85 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The rest of the body which is read when the body is closed before the end
+const DRAIN_BODY_SIZE = 256 << 10
+
+var ErrNotModified = errors.New("not modified")
+
+// ETag and Last-Modified of the last response of the url
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// Body of the response which calls the done when the body is read to the end.
+// The body which is closed before the end is drained, like the feed which parser stops at the root end tag.
+type completeBody struct {
+	io.ReadCloser
+	done     func()
+	complete bool
+	failed   bool
+}
+
+func (b *completeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF && !b.complete:
+		b.complete = true
+		b.done()
+	case err != nil && err != io.EOF:
+		b.failed = true
+	}
+	return n, err
+}
+
+func (b *completeBody) Close() error {
+	if !b.complete && !b.failed {
+		io.Copy(io.Discard, io.LimitReader(b, DRAIN_BODY_SIZE))
+	}
+	return b.ReadCloser.Close()
+}
+
+// Get the url with the If-None-Match and If-Modified-Since of its last response.
+// The error is ErrNotModified when the server responds 304, so the page is not parsed again.
+// The validators of the response are remembered when its body is read to the end.
+func (f *Fetcher) GetIfModified(ctx context.Context, url string) (*http.Response, error) {
+	f.mu.Lock()
+	last, ok := f.validators[url]
+	f.mu.Unlock()
+
+	header := make(http.Header)
+	if ok && last.etag != "" {
+		header.Set("If-None-Match", last.etag)
+	}
+	if ok && last.lastModified != "" {
+		header.Set("If-Modified-Since", last.lastModified)
+	}
+
+	resp, err := f.Do(ctx, http.MethodGet, url, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w %s", ErrNotModified, url)
+	}
+
+	next := validators{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	if next == (validators{}) {
+		return resp, nil
+	}
+	resp.Body = &completeBody{ReadCloser: resp.Body, done: func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.validators[url] = next
+	}}
+	return resp, nil
+}
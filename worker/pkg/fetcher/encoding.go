@@ -0,0 +1,54 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Encodings which the fetcher decodes
+const ACCEPT_ENCODING = "gzip, br"
+
+// Decoded body which closes the response body
+type decodedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	return b.body.Close()
+}
+
+// Replace the gzip or brotli body by the decoded one. The response without the body is kept.
+func decodeBody(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotModified || resp.StatusCode == http.StatusNoContent || resp.Request.Method == http.MethodHead {
+		return nil
+	}
+
+	var decoder io.Reader
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		decoder = reader
+	case "br":
+		decoder = brotli.NewReader(resp.Body)
+	default:
+		return fmt.Errorf("unsupported content encoding %q of %s", encoding, resp.Request.URL)
+	}
+
+	resp.Body = &decodedBody{Reader: decoder, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
@@ -0,0 +1,370 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_TIMEOUT     = 30 * time.Second
+	DEFAULT_MAX_RETRIES = 3
+	DEFAULT_BACKOFF     = time.Second
+	DEFAULT_MAX_BACKOFF = time.Minute
+	// Requests per second to the single host
+	DEFAULT_RATE_LIMIT = 1
+	DEFAULT_RATE_BURST = 2
+	DEFAULT_USER_AGENT = "news-tracker-worker/1.0 (+https://github.com/romashorodok/news-tracker)"
+	// Like the http.Client does by default
+	MAX_REDIRECTS = 10
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("unexpected status")
+	ErrTooManyRedirects = errors.New("stopped after redirects")
+)
+
+// The response status is the client or server error after all retries
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %d of %s", ErrUnexpectedStatus, e.StatusCode, e.URL)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnexpectedStatus
+}
+
+// The zero values are replaced by the defaults. The negative MaxRetries and RateLimit disable the retries and the rate limit.
+type Config struct {
+	// Timeout of the single attempt including the body read
+	Timeout    time.Duration
+	MaxRetries int
+	// Backoff of the first retry, it's doubled for each next one up to the MaxBackoff.
+	// The Retry-After longer than MaxBackoff is not waited, the response is the error.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	UserAgent  string
+	// Requests per second to the single host and how many of them may go at once
+	RateLimit float64
+	RateBurst int
+	// The http.DefaultTransport by default
+	Transport http.RoundTripper
+	// Fetch the urls which are disallowed by the robots.txt of the site
+	IgnoreRobots bool
+	// How long the robots.txt of the site is cached
+	RobotsTTL time.Duration
+	// Directory of the disk cache of the responses for the development. The cached url is not requested again.
+	CacheDir string
+	// How long the cached response is used, forever when it's zero
+	CacheTTL time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Timeout <= 0 {
+		c.Timeout = DEFAULT_TIMEOUT
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = DEFAULT_BACKOFF
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = DEFAULT_USER_AGENT
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = DEFAULT_RATE_LIMIT
+	}
+	if c.RateBurst <= 0 {
+		c.RateBurst = DEFAULT_RATE_BURST
+	}
+	if c.RobotsTTL <= 0 {
+		c.RobotsTTL = DEFAULT_ROBOTS_TTL
+	}
+	return c
+}
+
+// The zero values are taken from the base config
+func (c Config) with(base Config) Config {
+	if c.Timeout == 0 {
+		c.Timeout = base.Timeout
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = base.MaxRetries
+	}
+	if c.Backoff == 0 {
+		c.Backoff = base.Backoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = base.MaxBackoff
+	}
+	if c.UserAgent == "" {
+		c.UserAgent = base.UserAgent
+	}
+	if c.RateLimit == 0 {
+		c.RateLimit = base.RateLimit
+	}
+	if c.RateBurst == 0 {
+		c.RateBurst = base.RateBurst
+	}
+	if c.RobotsTTL == 0 {
+		c.RobotsTTL = base.RobotsTTL
+	}
+	if c.CacheDir == "" {
+		c.CacheDir, c.CacheTTL = base.CacheDir, base.CacheTTL
+	}
+	c.IgnoreRobots = c.IgnoreRobots || base.IgnoreRobots
+	c.Transport = base.Transport
+	return c
+}
+
+// State of the hosts which is shared by the handles of the fetcher
+type hosts struct {
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	robots  map[string]*hostRobots
+}
+
+// Fetcher is the http client which is polite to the sites.
+// It follows the robots.txt, limits the requests rate of each host, retries the failed requests and checks the response status.
+type Fetcher struct {
+	config Config
+	hosts  *hosts
+	// The client of the handle checks the redirects by its config
+	client *http.Client
+
+	// The validators of the handle, so the handles which get the same url don't take the changes of each other
+	mu         sync.Mutex
+	validators map[string]validators
+}
+
+// The handles of the same host share its bucket, each of them takes the tokens by its own rate
+func (f *Fetcher) bucket(host string) *tokenBucket {
+	f.hosts.mu.Lock()
+	defer f.hosts.mu.Unlock()
+
+	bucket, ok := f.hosts.buckets[host]
+	if !ok {
+		bucket = &tokenBucket{}
+		f.hosts.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// Server errors and the rate limit of the server are retried
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// Delay of the Retry-After header in seconds or the http date, zero when it's absent
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// Exponential backoff of the attempt with the jitter, so the retries of several workers are spread
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	backoff := f.config.Backoff << attempt
+	if backoff <= 0 || backoff > f.config.MaxBackoff {
+		backoff = f.config.MaxBackoff
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Body of the response which cancels the attempt timeout when it's closed
+type timeoutBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *timeoutBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (f *Fetcher) attempt(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.bucket(req.URL.Host).wait(ctx, f.config.RateLimit, f.config.RateBurst); err != nil {
+		return nil, err
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	req = req.WithContext(attemptCtx)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept-Encoding", ACCEPT_ENCODING)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &timeoutBody{ReadCloser: resp.Body, cancel: cancel}
+
+	if err := decodeBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Do the request with the retries. The response status is 2xx or 3xx which is not the redirect, like 304.
+// The url which is disallowed by the robots.txt is not requested, the error is ErrDisallowedByRobots.
+// The caller must close the body of the response.
+func (f *Fetcher) Do(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	useCache := f.config.CacheDir != "" && method == http.MethodGet
+	if useCache {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp, ok := f.cached(req); ok {
+			return resp, nil
+		}
+	}
+
+	if err := f.checkRobots(ctx, url); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := f.attempt(ctx, method, url, header)
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		var delay time.Duration
+		switch {
+		case errors.Is(err, ErrDisallowedByRobots):
+			// The redirect to the disallowed url is not retried
+			return nil, err
+
+		case err != nil:
+			if attempt >= f.config.MaxRetries {
+				return nil, err
+			}
+			delay = f.backoff(attempt)
+
+		case resp.StatusCode < 400:
+			if useCache && resp.StatusCode == http.StatusOK {
+				if err := f.storeCached(resp); err != nil {
+					log.Printf("Unable cache response of %s. Err: %s", url, err)
+				}
+			}
+			return resp, nil
+
+		default:
+			// The error page is drained, so the connection is reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+
+			statusErr := &StatusError{URL: url, StatusCode: resp.StatusCode}
+			if !isRetryableStatus(resp.StatusCode) || attempt >= f.config.MaxRetries {
+				return nil, statusErr
+			}
+
+			delay = f.backoff(attempt)
+			if after := retryAfter(resp); after > f.config.MaxBackoff {
+				return nil, fmt.Errorf("%w, retry after %s", statusErr, after.Round(time.Second))
+			} else if after > delay {
+				delay = after
+			}
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *Fetcher) Get(ctx context.Context, url string) (*http.Response, error) {
+	return f.Do(ctx, http.MethodGet, url, nil)
+}
+
+func New(config Config) *Fetcher {
+	config = config.withDefaults()
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return newHandle(config, &hosts{
+		transport: transport,
+		buckets:   make(map[string]*tokenBucket),
+		robots:    make(map[string]*hostRobots),
+	})
+}
+
+func newHandle(config Config, hosts *hosts) *Fetcher {
+	f := &Fetcher{
+		config:     config,
+		hosts:      hosts,
+		validators: make(map[string]validators),
+	}
+	f.client = &http.Client{Transport: hosts.transport, CheckRedirect: f.checkRedirect}
+	return f
+}
+
+// The redirect target is checked by the robots.txt and rate limit of its host like the requested url,
+// so the allowed url of one host doesn't lead to the disallowed url of other one.
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MAX_REDIRECTS {
+		return fmt.Errorf("%w %d", ErrTooManyRedirects, MAX_REDIRECTS)
+	}
+	// The robots.txt is not checked by itself, even when it's moved
+	if via[0].URL.Path != ROBOTS_PATH {
+		if err := f.checkRobots(req.Context(), req.URL.String()); err != nil {
+			return err
+		}
+	}
+	return f.bucket(req.URL.Host).wait(req.Context(), f.config.RateLimit, f.config.RateBurst)
+}
+
+// Handle of the fetcher with its own config, like the user agent and timeout of the template.
+// The zero values of the config are taken from the fetcher, the Transport is always the fetcher one.
+// The handles share the connections, the rate limit and the robots.txt of each host.
+func (f *Fetcher) With(config Config) *Fetcher {
+	config = config.with(f.config)
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	return newHandle(config, f.hosts)
+}
@@ -0,0 +1,56 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedirectCheckedByRobots(t *testing.T) {
+	var privateRequests atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ROBOTS_PATH:
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/private":
+			privateRequests.Add(1)
+			w.Write([]byte("private"))
+		default:
+			w.Write([]byte("public"))
+		}
+	}))
+	defer target.Close()
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ROBOTS_PATH:
+			http.Redirect(w, r, "/robots-moved.txt", http.StatusMovedPermanently)
+		case "/robots-moved.txt":
+			w.Write([]byte("User-agent: *\nAllow: /\n"))
+		case "/private":
+			http.Redirect(w, r, target.URL+"/private", http.StatusFound)
+		default:
+			http.Redirect(w, r, target.URL+"/public", http.StatusFound)
+		}
+	}))
+	defer source.Close()
+
+	fetcher := New(Config{RateLimit: -1})
+
+	resp, err := fetcher.Get(context.Background(), source.URL+"/public")
+	if err != nil {
+		t.Fatalf("allowed redirect failed: %s", err)
+	}
+	resp.Body.Close()
+
+	_, err = fetcher.Get(context.Background(), source.URL+"/private")
+	if !errors.Is(err, ErrDisallowedByRobots) {
+		t.Errorf("got %v, want the redirect disallowed by robots.txt", err)
+	}
+	if count := privateRequests.Load(); count != 0 {
+		t.Errorf("disallowed redirect target is requested %d times", count)
+	}
+}
@@ -0,0 +1,97 @@
+package fetcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Token bucket of the host. The bucket has the burst tokens at most and it's refilled by the rate tokens per second.
+// The zero rate is unlimited.
+//
+// The handles of the fetcher share the tokens of the host, but each of them takes the tokens by the rate and burst of its config.
+// So the slow config doesn't slow down the other handles after it's gone.
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// The crawl delay of the site slows down the rate of each config
+	crawlDelay time.Duration
+	// The bucket is full until the first token is taken
+	started bool
+}
+
+// Rate and burst of the config slowed down by the crawl delay
+func (b *tokenBucket) limit(rate float64, burst int) (float64, float64) {
+	if burst < 1 {
+		burst = 1
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	if b.crawlDelay > 0 {
+		if delayRate := 1 / b.crawlDelay.Seconds(); rate == 0 || delayRate < rate {
+			return delayRate, 1
+		}
+	}
+	return rate, float64(burst)
+}
+
+// Slow the bucket down to the single request per delay when it's slower than the config.
+// The zero delay restores the config rate.
+func (b *tokenBucket) setCrawlDelay(delay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.crawlDelay = delay
+}
+
+// Take the token by the rate and burst of the config and report how long to wait for it.
+// The token is reserved, so the concurrent requests queue up.
+func (b *tokenBucket) reserve(rate float64, burst int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rate, burstTokens := b.limit(rate, burst)
+	if rate == 0 {
+		return 0
+	}
+
+	now := time.Now()
+	if !b.started {
+		b.tokens = burstTokens
+		b.started = true
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+	}
+	if b.tokens > burstTokens {
+		b.tokens = burstTokens
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+func (b *tokenBucket) wait(ctx context.Context, rate float64, burst int) error {
+	delay := b.reserve(rate, burst)
+	if delay == 0 {
+		return nil
+	}
+	return sleep(ctx, delay)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,40 @@
+package fetcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRateOfEachConfig(t *testing.T) {
+	bucket := &tokenBucket{}
+
+	// The slow config takes the burst and waits for the next token
+	if wait := bucket.reserve(1, 1); wait != 0 {
+		t.Fatalf("first token waits %s", wait)
+	}
+	if wait := bucket.reserve(1, 1); wait < 900*time.Millisecond {
+		t.Fatalf("slow config waits %s, want about a second", wait)
+	}
+
+	// The fast config is not slowed down by the slow one, it only pays off the reserved token
+	if wait := bucket.reserve(1000, 1); wait > 10*time.Millisecond {
+		t.Errorf("fast config waits %s after the slow one", wait)
+	}
+}
+
+func TestTokenBucketCrawlDelay(t *testing.T) {
+	bucket := &tokenBucket{}
+	bucket.setCrawlDelay(time.Second)
+
+	bucket.reserve(1000, 10)
+	if wait := bucket.reserve(1000, 10); wait < 900*time.Millisecond {
+		t.Fatalf("crawl delay waits %s, want about a second", wait)
+	}
+
+	// The crawl delay is gone from the robots.txt, so the config rate is restored
+	bucket.setCrawlDelay(0)
+	time.Sleep(5 * time.Millisecond)
+	if wait := bucket.reserve(1000, 10); wait > 10*time.Millisecond {
+		t.Errorf("config rate waits %s after the crawl delay", wait)
+	}
+}
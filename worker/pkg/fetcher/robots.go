@@ -0,0 +1,139 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/robots"
+)
+
+const (
+	ROBOTS_PATH        = "/robots.txt"
+	DEFAULT_ROBOTS_TTL = 24 * time.Hour
+	// The robots.txt of the unreachable site is requested again sooner
+	ROBOTS_ERROR_TTL = 10 * time.Minute
+	// The rest of the bigger robots.txt is ignored
+	ROBOTS_MAX_SIZE = 512 << 10
+)
+
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// Cached robots.txt of the host. The nil robots disallows everything.
+type hostRobots struct {
+	mu      sync.Mutex
+	robots  *robots.Robots
+	expires time.Time
+}
+
+func (f *Fetcher) hostRobots(host string) *hostRobots {
+	f.hosts.mu.Lock()
+	defer f.hosts.mu.Unlock()
+
+	entry, ok := f.hosts.robots[host]
+	if !ok {
+		entry = &hostRobots{}
+		f.hosts.robots[host] = entry
+	}
+	return entry
+}
+
+// Get the robots.txt of the site and the time to keep it.
+//
+// The missing robots.txt, like 404, allows everything.
+// The server errors and the unreachable site disallow everything until the robots.txt is requested again.
+func (f *Fetcher) getRobots(ctx context.Context, site *url.URL) (*robots.Robots, time.Duration, error) {
+	robotsURL := (&url.URL{Scheme: site.Scheme, Host: site.Host, Path: ROBOTS_PATH}).String()
+
+	resp, err := f.attempt(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		log.Printf("Unable get %s, the site is disallowed. Err: %s", robotsURL, err)
+		return nil, ROBOTS_ERROR_TTL, nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case isRetryableStatus(resp.StatusCode):
+		log.Printf("Unable get %s, the site is disallowed. Err: %s", robotsURL, &StatusError{URL: robotsURL, StatusCode: resp.StatusCode})
+		return nil, ROBOTS_ERROR_TTL, nil
+	case resp.StatusCode >= 400:
+		return &robots.Robots{}, f.config.RobotsTTL, nil
+	}
+
+	siteRobots, err := robots.Parse(io.LimitReader(resp.Body, ROBOTS_MAX_SIZE))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		log.Printf("Unable read %s, the site is disallowed. Err: %s", robotsURL, err)
+		return nil, ROBOTS_ERROR_TTL, nil
+	}
+	return siteRobots, f.config.RobotsTTL, nil
+}
+
+// Robots.txt of the site from the cache, it's requested again when it's expired.
+// The Crawl-delay of the site slows down the rate limit of the host.
+func (f *Fetcher) siteRobots(ctx context.Context, site *url.URL) (*robots.Robots, error) {
+	entry := f.hostRobots(site.Host)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if time.Now().Before(entry.expires) {
+		return entry.robots, nil
+	}
+
+	siteRobots, ttl, err := f.getRobots(ctx, site)
+	if err != nil {
+		return nil, err
+	}
+	entry.robots = siteRobots
+	entry.expires = time.Now().Add(ttl)
+
+	var crawlDelay time.Duration
+	if siteRobots != nil {
+		crawlDelay = siteRobots.CrawlDelay(f.config.UserAgent)
+	}
+	f.bucket(site.Host).setCrawlDelay(crawlDelay)
+	return siteRobots, nil
+}
+
+// Check the url by the robots.txt of its site. The robots.txt itself is always allowed.
+func (f *Fetcher) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	if f.config.IgnoreRobots {
+		return true, nil
+	}
+
+	site, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	if site.Path == ROBOTS_PATH {
+		return true, nil
+	}
+
+	siteRobots, err := f.siteRobots(ctx, site)
+	if err != nil {
+		return false, err
+	}
+	return siteRobots != nil && siteRobots.Allowed(f.config.UserAgent, site.RequestURI()), nil
+}
+
+func (f *Fetcher) checkRobots(ctx context.Context, rawURL string) error {
+	allowed, err := f.Allowed(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%w %s", ErrDisallowedByRobots, rawURL)
+	}
+	return nil
+}
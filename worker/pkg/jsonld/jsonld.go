@@ -0,0 +1,187 @@
+package jsonld
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+const SCRIPT_SELECTOR = `script[type="application/ld+json" i]`
+
+// schema.org types which describe the article itself
+// https://schema.org/Article
+var ARTICLE_TYPES = []string{
+	"NewsArticle",
+	"Article",
+	"ReportageNewsArticle",
+	"AnalysisNewsArticle",
+	"OpinionNewsArticle",
+	"BackgroundNewsArticle",
+	"ReviewNewsArticle",
+	"LiveBlogPosting",
+	"BlogPosting",
+	"Report",
+}
+
+var ErrInvalidScript = errors.New("invalid json-ld script")
+
+// Object is the JSON-LD node like `{"@type": "NewsArticle", "headline": "..."}`
+type Object map[string]any
+
+// Values of the `@type`, it may be the string or the list
+func (o Object) Types() []string {
+	var types []string
+	switch value := o["@type"].(type) {
+	case string:
+		types = append(types, value)
+	case []any:
+		for _, item := range value {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	return types
+}
+
+// The type may be the full iri like `https://schema.org/NewsArticle`
+func (o Object) HasType(types ...string) bool {
+	for _, objectType := range o.Types() {
+		objectType = objectType[strings.LastIndexAny(objectType, "/:#")+1:]
+		for _, expected := range types {
+			if strings.EqualFold(objectType, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func scalarString(value any) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		// Some sites escape the values like html
+		if strings.IndexByte(value, '&') != -1 {
+			value = html.UnescapeString(value)
+		}
+		value = strings.TrimSpace(value)
+		return value, value != ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case map[string]any:
+		// Value object `{"@value": "..."}`
+		if inner, ok := value["@value"]; ok {
+			return scalarString(inner)
+		}
+	}
+	return "", false
+}
+
+func collectStrings(value any, path []string, result []string) []string {
+	if list, ok := value.([]any); ok {
+		for _, item := range list {
+			result = collectStrings(item, path, result)
+		}
+		return result
+	}
+
+	if len(path) == 0 {
+		if str, ok := scalarString(value); ok {
+			result = append(result, str)
+		}
+		return result
+	}
+
+	object, ok := value.(map[string]any)
+	if !ok {
+		return result
+	}
+	return collectStrings(object[path[0]], path[1:], result)
+}
+
+// String values of the properties by the dotted paths like `author.name`.
+// Lists are flattened, the objects at the end of the path are skipped.
+func (o Object) Strings(paths ...string) []string {
+	var result []string
+	for _, path := range paths {
+		result = collectStrings(map[string]any(o), strings.Split(path, "."), result)
+	}
+	return result
+}
+
+func flatten(value any, objects []Object) []Object {
+	switch value := value.(type) {
+	case []any:
+		for _, item := range value {
+			objects = flatten(item, objects)
+		}
+	case map[string]any:
+		objects = append(objects, Object(value))
+		// The article is often described as the main entity of the page or inside the graph
+		objects = flatten(value["@graph"], objects)
+		objects = flatten(value["mainEntity"], objects)
+	}
+	return objects
+}
+
+// Cut the html comment or cdata wrappers which are left by some CMS
+func unwrapScript(data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	for _, wrapper := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"//<![CDATA[", "//]]>"}} {
+		if bytes.HasPrefix(data, []byte(wrapper[0])) && bytes.HasSuffix(data, []byte(wrapper[1])) {
+			data = bytes.TrimSpace(data[len(wrapper[0]) : len(data)-len(wrapper[1])])
+		}
+	}
+	return data
+}
+
+// Decode the JSON-LD script content into the flat list of objects
+func Decode(data []byte) ([]Object, error) {
+	data = unwrapScript(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidScript, err)
+	}
+	return flatten(value, nil), nil
+}
+
+// Objects of all JSON-LD scripts of the document.
+// The invalid scripts are skipped, their errors are returned together with the objects of the valid ones.
+func Extract(doc *parser.Document) ([]Object, error) {
+	scripts, err := doc.QueryAll(SCRIPT_SELECTOR)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	var errs []error
+	for idx, script := range scripts {
+		decoded, err := Decode([]byte(script.Text()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("script %d: %w", idx, err))
+			continue
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, errors.Join(errs...)
+}
+
+// First object which describe the article or nil
+func FindArticle(objects []Object) Object {
+	for _, object := range objects {
+		if object.HasType(ARTICLE_TYPES...) {
+			return object
+		}
+	}
+	return nil
+}
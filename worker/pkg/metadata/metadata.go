@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+const (
+	META_SELECTOR      = "meta[content]"
+	CANONICAL_SELECTOR = "link[rel~=canonical i][href]"
+	// Key of the `<link rel="canonical">` url
+	CANONICAL = "canonical"
+)
+
+// Metadata of the page by the lowercased `property` or `name` of the `<meta>`.
+//
+// Example: `og:image`, `twitter:title`, `article:published_time`, `description`, `canonical`
+type Metadata map[string][]string
+
+// First value of the key or empty string
+func (m Metadata) Get(key string) string {
+	if values := m[strings.ToLower(key)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// All values of the key in document order, like the several `og:image` of the page
+func (m Metadata) Values(key string) []string {
+	return m[strings.ToLower(key)]
+}
+
+func (m Metadata) add(key, value string) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+	if key == "" || value == "" {
+		return
+	}
+	m[key] = append(m[key], value)
+}
+
+// Collect the `<meta>` and the canonical link of the page.
+// The OpenGraph uses the `property` and the Twitter Card uses the `name`, but sites mix them, so both are accepted.
+func Extract(doc *parser.Document) (Metadata, error) {
+	metadata := make(Metadata)
+
+	metas, err := doc.QueryAll(META_SELECTOR)
+	if err != nil {
+		return nil, err
+	}
+	for _, meta := range metas {
+		content := meta.Attr("content")
+		if property := meta.Attr("property"); property != "" {
+			metadata.add(property, content)
+			continue
+		}
+		metadata.add(meta.Attr("name"), content)
+	}
+
+	canonical, err := doc.Attr(CANONICAL_SELECTOR, "href")
+	if err != nil {
+		return nil, err
+	}
+	metadata.add(CANONICAL, canonical)
+
+	return metadata, nil
+}
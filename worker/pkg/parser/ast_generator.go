@@ -1,99 +1,117 @@
 package parser
 
-import (
-	"sync"
-	"sync/atomic"
-)
+import "strings"
 
+// How many nodes are allocated at once
+const NODES_CHUNK_SIZE = 64
+
+// AstGenerator is not safe for concurrent use. The parser drives each selector from one goroutine.
 type AstGenerator struct {
-	tagNames        map[string]*atomic.Int32
-	rootNode        *Node
-	nodes           []*Node
-	nodesMu         sync.Mutex
-	autoClosingNode bool
-	onTreeComplete  func(*Node)
+	rootNode *Node
+	// Stack of the open tags. Leaf nodes like text or `<img>` are never pushed here.
+	nodes []*Node
+	// Nodes are allocated by chunks. Completed trees may be kept by the callbacks,
+	// so the chunk is only appended and never overwritten.
+	chunk          []Node
+	onTreeComplete func(*Node)
 }
 
-func (t *AstGenerator) AppendOpenTag(node Node) {
-	t.nodesMu.Lock()
-	defer t.nodesMu.Unlock()
-
-	if t.nodes == nil {
-		t.nodes = make([]*Node, 0)
+func (t *AstGenerator) newNode(node Node) *Node {
+	if len(t.chunk) == cap(t.chunk) {
+		t.chunk = make([]Node, 0, NODES_CHUNK_SIZE)
 	}
+	t.chunk = append(t.chunk, node)
+	return &t.chunk[len(t.chunk)-1]
+}
 
-	tagNameCounter, tagNameExists := t.tagNames[node.Name]
-	if !tagNameExists {
-		var counter atomic.Int32
-		counter.Add(1)
-		t.tagNames[node.Name] = &counter
-	} else {
-		tagNameCounter.Add(1)
+// Close the open tags which end tag is implied by the opening node, like the `<li>` closes the previous `<li>`.
+// Reports whether the whole tree is completed by that, then the node is not appended to it.
+func (t *AstGenerator) closeImplied(node Node) bool {
+	if node.Type == TEXT_NODE {
+		return false
 	}
 
-	t.nodes = append(t.nodes, &node)
-
-	if t.rootNode == nil {
-		t.rootNode = &node
+	idx := len(t.nodes)
+	for idx > 0 && IsImpliedEndTag(t.nodes[idx-1].Name, node.Name) {
+		idx--
+	}
+	if idx == len(t.nodes) {
+		return false
 	}
-}
 
-func (t *AstGenerator) nextNode() *Node {
-	if len(t.nodes) > 0 {
-		node := t.nodes[len(t.nodes)-1]
-		t.nodes = t.nodes[:len(t.nodes)-1]
-		return node
+	t.nodes = t.nodes[:idx]
+	if idx == 0 && t.rootNode != nil {
+		t.onTreeComplete(t.rootNode)
+		return true
 	}
-	return nil
+	return false
 }
 
-func (t *AstGenerator) CloseTag(closingNode Node) {
-	t.nodesMu.Lock()
-	defer t.nodesMu.Unlock()
-
-	tagNameCounter, tagNameExist := t.tagNames[closingNode.Name]
-	if !tagNameExist {
+func (t *AstGenerator) AppendOpenTag(node Node) {
+	if t.closeImplied(node) {
 		return
 	}
+	parentNode := t.PendingNode()
 
-	tagNameCounter.Add(-1)
-	if tagNameCounter.Load() == 0 {
-		delete(t.tagNames, closingNode.Name)
+	if parentNode == nil {
+		// The text can't be the root of the tree
+		if t.rootNode != nil || node.Type == TEXT_NODE {
+			return
+		}
 	}
 
-	targetNode := t.nextNode()
+	newNode := t.newNode(node)
 
-	// If target node is not correspond to the closing node.
-	// It may be by inconsistent stack or somewhere was lost closing tag.
-	// And need to complete open tag anyway. But what to do with lost open tag?
-	for len(t.nodes) > 0 && targetNode.Name != closingNode.Name {
-		nodeToSkip := targetNode
-
-		targetNode = t.nextNode()
+	if parentNode != nil {
+		parentNode.AppendChild(newNode)
+	} else {
+		t.rootNode = newNode
 
-		// Link lost node as the Next node of the tree stack
-		if t.autoClosingNode {
-			targetNodeLastChild := LastChildOfNode(targetNode)
-			targetNodeLastChild.Next = nodeToSkip
+		// Leaf will never be closed, so the tree is complete
+		if newNode.IsLeaf() {
+			t.onTreeComplete(t.rootNode)
+			return
 		}
+	}
 
-		if targetNode.Name == closingNode.Name {
-			break
-		}
+	if !newNode.IsLeaf() {
+		t.nodes = append(t.nodes, newNode)
 	}
+}
 
-	if targetNode.Name == closingNode.Name {
-		if parentNode := t.PendingNode(); parentNode != nil {
-			parentNodeLastChild := LastChildOfNode(parentNode)
-			parentNodeLastChild.Next = targetNode
+func (t *AstGenerator) CloseTag(closingNode Node) {
+	// Find the nearest open tag which correspond to the closing node.
+	// The open tags above it lost their closing tags. They are closed together with it
+	// and keep their children, so the content is not lost.
+	// When there is no such open tag the closing tag is ignored.
+	targetIdx := -1
+	for idx := len(t.nodes) - 1; idx >= 0; idx-- {
+		// The tag names are case-insensitive, like `<DIV>...</div>`
+		if strings.EqualFold(t.nodes[idx].Name, closingNode.Name) {
+			targetIdx = idx
+			break
 		}
 	}
+	if targetIdx == -1 {
+		return
+	}
 
-	if targetNode != nil && t.rootNode == targetNode {
+	t.nodes = t.nodes[:targetIdx]
+
+	if len(t.nodes) == 0 && t.rootNode != nil {
 		t.onTreeComplete(t.rootNode)
 	}
 }
 
+// Complete the pending tree, when the tag out of it is closed, like the `</ul>` of the selected `<li>`
+func (t *AstGenerator) Complete() {
+	if t.rootNode == nil {
+		return
+	}
+	t.nodes = t.nodes[:0]
+	t.onTreeComplete(t.rootNode)
+}
+
 func (t *AstGenerator) PendingNode() *Node {
 	if len(t.nodes) > 0 {
 		return t.nodes[len(t.nodes)-1]
@@ -111,14 +129,11 @@ func (t *AstGenerator) IsBuilding() bool {
 
 func (t *AstGenerator) Free() {
 	t.rootNode = nil
-	t.nodes = make([]*Node, 0)
-	t.tagNames = make(map[string]*atomic.Int32)
+	t.nodes = t.nodes[:0]
 }
 
 func NewAstGenerator() *AstGenerator {
 	return &AstGenerator{
-		tagNames:        make(map[string]*atomic.Int32),
-		nodes:           make([]*Node, 0),
-		autoClosingNode: true,
+		nodes: make([]*Node, 0),
 	}
 }
@@ -0,0 +1,489 @@
+package css
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type attrSelector struct {
+	key             string
+	operator        AttrOperator
+	value           string
+	caseInsensitive bool
+}
+
+// a*n + b expression of the :nth-* pseudo-classes
+type nthExpression struct {
+	a, b int
+}
+
+func (e nthExpression) matches(index int) bool {
+	if e.a == 0 {
+		return index == e.b
+	}
+	n := index - e.b
+	return n%e.a == 0 && n/e.a >= 0
+}
+
+type pseudoSelector struct {
+	class PseudoClass
+	nth   nthExpression
+	not   []*compoundSelector
+}
+
+// Sequence of simple selectors without combinators like `div#main.news[data-id]:first-child`
+type compoundSelector struct {
+	name    string
+	id      string
+	classes []string
+	attrs   []attrSelector
+	pseudos []pseudoSelector
+}
+
+// Compound selectors joined by combinators.
+// Stored from right to left, the combinator of the part points to the next part.
+//
+// Example: `ul.news > li a` is stored as
+// [{a, ' '}, {li, '>'}, {ul.news, 0}]
+type complexSelectorPart struct {
+	compound   *compoundSelector
+	combinator Combinator
+}
+
+type complexSelector []complexSelectorPart
+
+// Selector is compiled css selector list
+type Selector struct {
+	source    string
+	selectors []complexSelector
+}
+
+func (s *Selector) String() string {
+	return s.source
+}
+
+type compiler struct {
+	source string
+	pos    int
+}
+
+func (c *compiler) errorf(err error, format string, args ...any) error {
+	return errors.Join(err, fmt.Errorf("at %d in %q: %s", c.pos, c.source, fmt.Sprintf(format, args...)))
+}
+
+func (c *compiler) eof() bool {
+	return c.pos >= len(c.source)
+}
+
+func (c *compiler) peek() byte {
+	if c.eof() {
+		return 0
+	}
+	return c.source[c.pos]
+}
+
+func isSpace(symbol byte) bool {
+	switch symbol {
+	case ' ', '\n', '\r', '\t', '\f':
+		return true
+	}
+	return false
+}
+
+func isIdentSymbol(symbol byte) bool {
+	return 'a' <= symbol && symbol <= 'z' ||
+		'A' <= symbol && symbol <= 'Z' ||
+		'0' <= symbol && symbol <= '9' ||
+		symbol == '-' || symbol == '_' || symbol >= 0x80
+}
+
+func (c *compiler) skipSpaces() bool {
+	start := c.pos
+	for !c.eof() && isSpace(c.peek()) {
+		c.pos++
+	}
+	return c.pos > start
+}
+
+func (c *compiler) ident() (string, error) {
+	var ident strings.Builder
+	for !c.eof() {
+		symbol := c.peek()
+		switch {
+		case symbol == '\\':
+			c.pos++
+			if c.eof() {
+				return "", c.errorf(ErrUnexpectedEnd, "escape without symbol")
+			}
+			ident.WriteByte(c.peek())
+			c.pos++
+		case isIdentSymbol(symbol):
+			ident.WriteByte(symbol)
+			c.pos++
+		default:
+			if ident.Len() == 0 {
+				return "", c.errorf(ErrUnexpectedSymbol, "expected identifier got %q", symbol)
+			}
+			return ident.String(), nil
+		}
+	}
+	if ident.Len() == 0 {
+		return "", c.errorf(ErrUnexpectedEnd, "expected identifier")
+	}
+	return ident.String(), nil
+}
+
+func (c *compiler) quotedString() (string, error) {
+	quote := c.peek()
+	c.pos++
+
+	var value strings.Builder
+	for !c.eof() {
+		symbol := c.peek()
+		c.pos++
+		switch symbol {
+		case quote:
+			return value.String(), nil
+		case '\\':
+			if c.eof() {
+				return "", c.errorf(ErrUnterminatedString, "escape without symbol")
+			}
+			value.WriteByte(c.peek())
+			c.pos++
+		default:
+			value.WriteByte(symbol)
+		}
+	}
+	return "", c.errorf(ErrUnterminatedString, "missing closing %q", quote)
+}
+
+func (c *compiler) attr() (attrSelector, error) {
+	// Skip `[`
+	c.pos++
+	c.skipSpaces()
+
+	key, err := c.ident()
+	if err != nil {
+		return attrSelector{}, err
+	}
+	attr := attrSelector{key: strings.ToLower(key)}
+	c.skipSpaces()
+
+	switch symbol := c.peek(); symbol {
+	case ']':
+		c.pos++
+		return attr, nil
+	case '=':
+		attr.operator = ATTR_EQUALS
+		c.pos++
+	case '~', '|', '^', '$', '*':
+		c.pos++
+		if c.peek() != '=' {
+			return attrSelector{}, c.errorf(ErrInvalidAttrSelector, "expected `=` after %q", symbol)
+		}
+		attr.operator = AttrOperator([]byte{symbol, '='})
+		c.pos++
+	default:
+		return attrSelector{}, c.errorf(ErrInvalidAttrSelector, "unexpected %q", symbol)
+	}
+	c.skipSpaces()
+
+	switch c.peek() {
+	case '"', '\'':
+		attr.value, err = c.quotedString()
+	default:
+		attr.value, err = c.ident()
+	}
+	if err != nil {
+		return attrSelector{}, err
+	}
+	c.skipSpaces()
+
+	if symbol := c.peek(); symbol == 'i' || symbol == 'I' {
+		attr.caseInsensitive = true
+		c.pos++
+		c.skipSpaces()
+	}
+
+	if c.peek() != ']' {
+		return attrSelector{}, c.errorf(ErrInvalidAttrSelector, "missing `]`")
+	}
+	c.pos++
+	return attr, nil
+}
+
+// Parse the `an+b`, `odd`, `even` expressions
+func parseNth(expr string) (nthExpression, error) {
+	expr = strings.ToLower(strings.ReplaceAll(expr, " ", ""))
+
+	switch expr {
+	case "odd":
+		return nthExpression{a: 2, b: 1}, nil
+	case "even":
+		return nthExpression{a: 2, b: 0}, nil
+	case "":
+		return nthExpression{}, ErrInvalidNthExpression
+	}
+
+	nIdx := strings.IndexByte(expr, 'n')
+	if nIdx == -1 {
+		b, err := strconv.Atoi(expr)
+		if err != nil {
+			return nthExpression{}, ErrInvalidNthExpression
+		}
+		return nthExpression{b: b}, nil
+	}
+
+	var result nthExpression
+	switch aStr := expr[:nIdx]; aStr {
+	case "", "+":
+		result.a = 1
+	case "-":
+		result.a = -1
+	default:
+		a, err := strconv.Atoi(aStr)
+		if err != nil {
+			return nthExpression{}, ErrInvalidNthExpression
+		}
+		result.a = a
+	}
+
+	if bStr := expr[nIdx+1:]; bStr != "" {
+		if bStr[0] != '+' && bStr[0] != '-' {
+			return nthExpression{}, ErrInvalidNthExpression
+		}
+		b, err := strconv.Atoi(bStr)
+		if err != nil {
+			return nthExpression{}, ErrInvalidNthExpression
+		}
+		result.b = b
+	}
+
+	return result, nil
+}
+
+func (c *compiler) pseudoArgument() (string, error) {
+	if c.peek() != '(' {
+		return "", c.errorf(ErrUnexpectedSymbol, "expected `(`")
+	}
+	c.pos++
+
+	start, depth := c.pos, 1
+	for !c.eof() {
+		switch c.peek() {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				arg := c.source[start:c.pos]
+				c.pos++
+				return arg, nil
+			}
+		}
+		c.pos++
+	}
+	return "", c.errorf(ErrUnexpectedEnd, "missing `)`")
+}
+
+func (c *compiler) pseudo() (pseudoSelector, error) {
+	// Skip `:`
+	c.pos++
+
+	name, err := c.ident()
+	if err != nil {
+		return pseudoSelector{}, err
+	}
+
+	pseudo := pseudoSelector{class: PseudoClass(strings.ToLower(name))}
+
+	switch pseudo.class {
+	case PSEUDO_ROOT:
+	case PSEUDO_FIRST_CHILD, PSEUDO_FIRST_OF_TYPE:
+		pseudo.nth = nthExpression{b: 1}
+
+	case PSEUDO_NTH_CHILD, PSEUDO_NTH_OF_TYPE:
+		arg, err := c.pseudoArgument()
+		if err != nil {
+			return pseudoSelector{}, err
+		}
+		if pseudo.nth, err = parseNth(arg); err != nil {
+			return pseudoSelector{}, c.errorf(err, "%q", arg)
+		}
+
+	case PSEUDO_NOT:
+		arg, err := c.pseudoArgument()
+		if err != nil {
+			return pseudoSelector{}, err
+		}
+		selector, err := Compile(arg)
+		if err != nil {
+			return pseudoSelector{}, err
+		}
+		for _, complex := range selector.selectors {
+			if len(complex) != 1 {
+				return pseudoSelector{}, c.errorf(ErrNestedComplexSelector, "%q", arg)
+			}
+			pseudo.not = append(pseudo.not, complex[0].compound)
+		}
+
+	default:
+		return pseudoSelector{}, c.errorf(ErrUnsupportedPseudo, "%q", name)
+	}
+
+	return pseudo, nil
+}
+
+func (c *compiler) compound() (*compoundSelector, error) {
+	compound := &compoundSelector{}
+	start := c.pos
+
+	switch symbol := c.peek(); {
+	case symbol == '*':
+		c.pos++
+	case isIdentSymbol(symbol) || symbol == '\\':
+		name, err := c.ident()
+		if err != nil {
+			return nil, err
+		}
+		compound.name = strings.ToLower(name)
+	}
+
+loop:
+	for !c.eof() {
+		switch c.peek() {
+		case '#':
+			c.pos++
+			id, err := c.ident()
+			if err != nil {
+				return nil, err
+			}
+			compound.id = id
+		case '.':
+			c.pos++
+			class, err := c.ident()
+			if err != nil {
+				return nil, err
+			}
+			compound.classes = append(compound.classes, class)
+		case '[':
+			attr, err := c.attr()
+			if err != nil {
+				return nil, err
+			}
+			compound.attrs = append(compound.attrs, attr)
+		case ':':
+			pseudo, err := c.pseudo()
+			if err != nil {
+				return nil, err
+			}
+			compound.pseudos = append(compound.pseudos, pseudo)
+		default:
+			break loop
+		}
+	}
+
+	if c.pos == start {
+		if c.eof() {
+			return nil, c.errorf(ErrUnexpectedEnd, "expected selector")
+		}
+		return nil, c.errorf(ErrUnexpectedSymbol, "%q", c.peek())
+	}
+
+	return compound, nil
+}
+
+func (c *compiler) complex() (complexSelector, error) {
+	var parts complexSelector
+
+	for {
+		compound, err := c.compound()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, complexSelectorPart{compound: compound})
+
+		hasSpace := c.skipSpaces()
+		if c.eof() || c.peek() == ',' {
+			break
+		}
+
+		var combinator Combinator
+		switch symbol := c.peek(); symbol {
+		case '>', '+', '~':
+			combinator = Combinator(symbol)
+			c.pos++
+			c.skipSpaces()
+			if c.eof() || c.peek() == ',' {
+				return nil, c.errorf(ErrDanglingCombinator, "%q", symbol)
+			}
+		default:
+			if !hasSpace {
+				return nil, c.errorf(ErrUnexpectedSymbol, "%q", symbol)
+			}
+			combinator = COMBINATOR_DESCENDANT
+		}
+		parts[len(parts)-1].combinator = combinator
+	}
+
+	// Reverse parts to match from the right-most compound selector
+	// and shift combinators to point from the right part to the left part.
+	reversed := make(complexSelector, len(parts))
+	for i := range reversed {
+		reversed[i].compound = parts[len(parts)-1-i].compound
+		if left := len(parts) - 2 - i; left >= 0 {
+			reversed[i].combinator = parts[left].combinator
+		}
+	}
+
+	return reversed, nil
+}
+
+// Compile the css selector list.
+//
+// Supported:
+// type `div`, universal `*`, id `#main`, class `.news`,
+// attributes `[href]`, `[rel=next]`, `[class~=a]`, `[lang|=uk]`, `[href^=https]`, `[src$=".png"]`, `[class*=item i]`,
+// combinators descendant ` `, child `>`, adjacent sibling `+`, general sibling `~`,
+// pseudo-classes `:root`, `:first-child`, `:nth-child(an+b)`, `:first-of-type`, `:nth-of-type(an+b)`, `:not(compound)`
+// and selector lists `h1, h2`.
+func Compile(source string) (*Selector, error) {
+	c := &compiler{source: source}
+	selector := &Selector{source: source}
+
+	c.skipSpaces()
+	if c.eof() {
+		return nil, ErrEmptySelector
+	}
+
+	for {
+		complex, err := c.complex()
+		if err != nil {
+			return nil, err
+		}
+		selector.selectors = append(selector.selectors, complex)
+
+		if c.eof() {
+			break
+		}
+
+		// Skip `,`
+		c.pos++
+		c.skipSpaces()
+		if c.eof() {
+			return nil, c.errorf(ErrUnexpectedEnd, "expected selector after `,`")
+		}
+	}
+
+	return selector, nil
+}
+
+func MustCompile(source string) *Selector {
+	selector, err := Compile(source)
+	if err != nil {
+		panic(err)
+	}
+	return selector
+}
@@ -0,0 +1,152 @@
+package css
+
+import "strings"
+
+func containsWord(value, word string, caseInsensitive bool) bool {
+	for _, field := range strings.Fields(value) {
+		if field == word || caseInsensitive && strings.EqualFold(field, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a attrSelector) matches(element Element) bool {
+	value, ok := element.Attr(a.key)
+	if !ok {
+		return false
+	}
+
+	expected := a.value
+	if a.caseInsensitive {
+		value = strings.ToLower(value)
+		expected = strings.ToLower(expected)
+	}
+
+	switch a.operator {
+	case ATTR_EXISTS:
+		return true
+	case ATTR_EQUALS:
+		return value == expected
+	case ATTR_INCLUDES:
+		return containsWord(value, expected, false)
+	case ATTR_DASH:
+		return value == expected || strings.HasPrefix(value, expected+"-")
+	case ATTR_PREFIX:
+		return expected != "" && strings.HasPrefix(value, expected)
+	case ATTR_SUFFIX:
+		return expected != "" && strings.HasSuffix(value, expected)
+	case ATTR_SUBSTRING:
+		return expected != "" && strings.Contains(value, expected)
+	}
+	return false
+}
+
+func (p pseudoSelector) matches(element Element) bool {
+	switch p.class {
+	case PSEUDO_ROOT:
+		return element.Parent() == nil
+	case PSEUDO_FIRST_CHILD, PSEUDO_NTH_CHILD:
+		return p.nth.matches(element.Index())
+	case PSEUDO_FIRST_OF_TYPE, PSEUDO_NTH_OF_TYPE:
+		return p.nth.matches(element.TypeIndex())
+	case PSEUDO_NOT:
+		for _, compound := range p.not {
+			if compound.matches(element) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (s *compoundSelector) matches(element Element) bool {
+	if s.name != "" && !strings.EqualFold(s.name, element.Name()) {
+		return false
+	}
+
+	if s.id != "" {
+		if id, ok := element.Attr("id"); !ok || id != s.id {
+			return false
+		}
+	}
+
+	if len(s.classes) > 0 {
+		classes, ok := element.Attr("class")
+		if !ok {
+			return false
+		}
+		for _, class := range s.classes {
+			if !containsWord(classes, class, false) {
+				return false
+			}
+		}
+	}
+
+	for _, attr := range s.attrs {
+		if !attr.matches(element) {
+			return false
+		}
+	}
+
+	for _, pseudo := range s.pseudos {
+		if !pseudo.matches(element) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match parts from the right to the left, backtracking on descendant and sibling combinators.
+func (s complexSelector) matches(element Element) bool {
+	if len(s) == 0 || !s[0].compound.matches(element) {
+		return false
+	}
+	return s.matchesFrom(1, s[0].combinator, element)
+}
+
+func (s complexSelector) matchesFrom(idx int, combinator Combinator, element Element) bool {
+	if idx >= len(s) {
+		return true
+	}
+
+	part := s[idx]
+
+	switch combinator {
+	case COMBINATOR_CHILD:
+		parent := element.Parent()
+		return parent != nil && part.compound.matches(parent) && s.matchesFrom(idx+1, part.combinator, parent)
+
+	case COMBINATOR_DESCENDANT:
+		for ancestor := element.Parent(); ancestor != nil; ancestor = ancestor.Parent() {
+			if part.compound.matches(ancestor) && s.matchesFrom(idx+1, part.combinator, ancestor) {
+				return true
+			}
+		}
+
+	case COMBINATOR_ADJACENT:
+		sibling := element.PrevSibling()
+		return sibling != nil && part.compound.matches(sibling) && s.matchesFrom(idx+1, part.combinator, sibling)
+
+	case COMBINATOR_SIBLING:
+		for sibling := element.PrevSibling(); sibling != nil; sibling = sibling.PrevSibling() {
+			if part.compound.matches(sibling) && s.matchesFrom(idx+1, part.combinator, sibling) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Match reports whether the element is selected by any selector of the list
+func (s *Selector) Match(element Element) bool {
+	for _, complex := range s.selectors {
+		if complex.matches(element) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package css
+
+import "errors"
+
+// Element is the view of a document element which the selector matching needs.
+// Streaming selectors implement it over their stack of open tags,
+// so matching never require the whole document to be in memory.
+type Element interface {
+	Name() string
+	Attr(key string) (string, bool)
+	// Parent element or nil when the element is the root of the document
+	Parent() Element
+	// Previous element sibling or nil when the element is the first child
+	PrevSibling() Element
+	// Position of the element among parent element children, starts from 1
+	Index() int
+	// Position of the element among parent element children with the same name, starts from 1
+	TypeIndex() int
+}
+
+type Combinator byte
+
+const (
+	COMBINATOR_NONE       Combinator = 0
+	COMBINATOR_DESCENDANT Combinator = ' '
+	COMBINATOR_CHILD      Combinator = '>'
+	COMBINATOR_ADJACENT   Combinator = '+'
+	COMBINATOR_SIBLING    Combinator = '~'
+)
+
+type AttrOperator string
+
+const (
+	ATTR_EXISTS    AttrOperator = ""
+	ATTR_EQUALS    AttrOperator = "="
+	ATTR_INCLUDES  AttrOperator = "~="
+	ATTR_DASH      AttrOperator = "|="
+	ATTR_PREFIX    AttrOperator = "^="
+	ATTR_SUFFIX    AttrOperator = "$="
+	ATTR_SUBSTRING AttrOperator = "*="
+)
+
+type PseudoClass string
+
+const (
+	PSEUDO_ROOT          PseudoClass = "root"
+	PSEUDO_FIRST_CHILD   PseudoClass = "first-child"
+	PSEUDO_NTH_CHILD     PseudoClass = "nth-child"
+	PSEUDO_FIRST_OF_TYPE PseudoClass = "first-of-type"
+	PSEUDO_NTH_OF_TYPE   PseudoClass = "nth-of-type"
+	PSEUDO_NOT           PseudoClass = "not"
+)
+
+var (
+	ErrEmptySelector         = errors.New("empty selector")
+	ErrUnexpectedEnd         = errors.New("unexpected end of selector")
+	ErrUnexpectedSymbol      = errors.New("unexpected symbol")
+	ErrUnsupportedPseudo     = errors.New("unsupported pseudo-class")
+	ErrInvalidNthExpression  = errors.New("invalid nth expression")
+	ErrInvalidAttrSelector   = errors.New("invalid attribute selector")
+	ErrUnterminatedString    = errors.New("unterminated string")
+	ErrDanglingCombinator    = errors.New("combinator without right-hand selector")
+	ErrNestedComplexSelector = errors.New(":not accepts only compound selectors")
+)
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
+)
+
+type trackedTag struct {
+	name                 string
+	offset, line, column int
+}
+
+// Track open tags of the whole document to report the unexpected closing tags and unclosed tags.
+// It doesn't affect the selectors, they recover from that problems by themselves.
+type openTagsTracker struct {
+	tags []trackedTag
+}
+
+func (t *openTagsTracker) open(tok *Tokenizer, tag token.OpenTag) {
+	if tag.SelfClosing || IsVoidElement(tag.Name) {
+		return
+	}
+
+	offset, line, column := tok.Position()
+	t.tags = append(t.tags, trackedTag{
+		name:   strings.ToLower(tag.Name),
+		offset: offset,
+		line:   line,
+		column: column,
+	})
+}
+
+func (t *openTagsTracker) reportUnclosed(tok *Tokenizer, tags []trackedTag) {
+	for _, tag := range tags {
+		if _, optional := OPTIONAL_END_TAG_ELEMENTS[tag.name]; optional {
+			continue
+		}
+		tok.AddDiagnostic(&ParseError{
+			Offset:  tag.offset,
+			Line:    tag.line,
+			Column:  tag.column,
+			Snippet: "<" + tag.name,
+			Err:     fmt.Errorf("%w <%s>", ErrUnclosedTag, tag.name),
+		})
+	}
+}
+
+func (t *openTagsTracker) close(tok *Tokenizer, tag token.CloseTag) {
+	name := strings.ToLower(tag.Name)
+
+	for idx := len(t.tags) - 1; idx >= 0; idx-- {
+		if t.tags[idx].name == name {
+			t.reportUnclosed(tok, t.tags[idx+1:])
+			t.tags = t.tags[:idx]
+			return
+		}
+	}
+
+	if IsVoidElement(name) {
+		return
+	}
+	tok.report(fmt.Errorf("%w </%s>", ErrUnexpectedCloseTag, name))
+}
+
+func (t *openTagsTracker) end(tok *Tokenizer) {
+	t.reportUnclosed(tok, t.tags)
+	t.tags = nil
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"io"
+)
+
+// Document is the whole parsed tree. Unlike the selectors it's built once and may be queried many times.
+type Document struct {
+	// Root is not an element, the top level elements and text are its children
+	Root *Node
+	// Non-fatal problems of the document
+	Diagnostics Diagnostics
+}
+
+// Build the tree of the whole document by the same rules as the selectors build their trees
+type documentBuilder struct {
+	ast *AstGenerator
+}
+
+func (b *documentBuilder) OnOpen(node Node) {
+	b.ast.AppendOpenTag(node)
+}
+
+func (b *documentBuilder) OnClose(node Node) {
+	b.ast.CloseTag(node)
+}
+
+func (b *documentBuilder) GetPendingNode() *Node {
+	return b.ast.PendingNode()
+}
+
+var _ Selector = (*documentBuilder)(nil)
+
+func newDocumentBuilder() *documentBuilder {
+	ast := NewAstGenerator()
+	// The document node is never closed, so the tree is never completed by the generator
+	ast.OnTreeComplete(func(*Node) {})
+	ast.AppendOpenTag(Node{Name: DOCUMENT_NODE_NAME, Type: DOCUMENT_NODE})
+	return &documentBuilder{ast: ast}
+}
+
+// Parse the whole document into the tree.
+// Returns the error only when the document can't be read, the parse problems are in the Document.Diagnostics.
+func ParseDocument(file io.Reader) (*Document, error) {
+	return ParseDocumentWithOptions(file, ParseOptions{})
+}
+
+func ParseDocumentWithOptions(file io.Reader, options ParseOptions) (*Document, error) {
+	tok := NewTokenizer(file)
+	tok.RawEntities = options.RawEntities
+
+	builder := newDocumentBuilder()
+	diagnostics := parseTokens(tok, []Selector{builder})
+	if tok.Err != nil && tok.Err != io.EOF {
+		return nil, tok.Err
+	}
+
+	return &Document{
+		Root:        builder.ast.rootNode,
+		Diagnostics: diagnostics,
+	}, nil
+}
+
+// First element which match the css selector
+func (d *Document) Query(query string) (*Node, error) {
+	return d.Root.Query(query)
+}
+
+// All elements which match the css selector in document order
+func (d *Document) QueryAll(query string) ([]*Node, error) {
+	return d.Root.QueryAll(query)
+}
+
+// Text of the first element which match the css selector. Empty when nothing is matched.
+func (d *Document) Text(query string) (string, error) {
+	node, err := d.Query(query)
+	if err != nil || node == nil {
+		return "", err
+	}
+	return node.Text(), nil
+}
+
+// Attribute value of the first element which match the css selector. Empty when nothing is matched.
+func (d *Document) Attr(query, key string) (string, error) {
+	node, err := d.Query(query)
+	if err != nil || node == nil {
+		return "", err
+	}
+	return node.Attr(key), nil
+}
+
+// Markup of the first element content which match the css selector. Empty when nothing is matched.
+func (d *Document) InnerHTML(query string) (string, error) {
+	node, err := d.Query(query)
+	if err != nil || node == nil {
+		return "", err
+	}
+	return node.InnerHTML(), nil
+}
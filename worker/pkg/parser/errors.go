@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	MAX_PARSE_DIAGNOSTICS   = 100
+	MAX_PARSE_ERROR_SNIPPET = 64
+)
+
+var (
+	ErrUnexpectedEOF       = errors.New("unexpected end of document inside the tag")
+	ErrUnterminatedComment = errors.New("unterminated comment")
+	ErrUnterminatedCDATA   = errors.New("unterminated cdata section")
+	ErrUnterminatedRawText = errors.New("unterminated raw text element")
+	ErrUnexpectedCloseTag  = errors.New("unexpected closing tag")
+	ErrUnclosedTag         = errors.New("tag is not closed")
+	ErrInvalidOpenTag      = errors.New("invalid open tag")
+	ErrInvalidCloseTag     = errors.New("invalid closing tag")
+	ErrTooManyDiagnostics  = errors.New("too many diagnostics, the rest is omitted")
+)
+
+// ParseError is the non-fatal problem of the document. The parser recover from it and continue.
+type ParseError struct {
+	// Byte offset from the beginning of the document
+	Offset int
+	// Line and column start from 1. Column is counted in bytes.
+	Line   int
+	Column int
+	// Source of the token where the problem is found
+	Snippet string
+	Err     error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d (offset %d): %s near %q", e.Line, e.Column, e.Offset, e.Err, e.Snippet)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+type Diagnostics []*ParseError
+
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	errs := make([]error, len(d))
+	for idx, diagnostic := range d {
+		errs[idx] = diagnostic
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"strings"
+)
+
+func (n *Node) AppendChild(child *Node) {
+	child.Parent = n
+	child.PrevSibling = n.LastChild
+	child.NextSibling = nil
+	child.Depth = n.Depth + 1
+
+	if n.LastChild != nil {
+		n.LastChild.NextSibling = child
+	} else {
+		n.FirstChild = child
+	}
+	n.LastChild = child
+}
+
+func (n *Node) IsElement() bool {
+	return n.Type == OPEN_NODE
+}
+
+// Text nodes, void elements and self-closing tags can't have children and never closed
+func (n *Node) IsLeaf() bool {
+	return n.Type == TEXT_NODE || n.Tag.SelfClosing || IsVoidElement(n.Name)
+}
+
+func (n *Node) Attr(key string) string {
+	if n.Tag.Attr == nil {
+		return ""
+	}
+	return n.Tag.Attr[key]
+}
+
+// Direct children of the node including text nodes
+func (n *Node) Children() []*Node {
+	var children []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+	}
+	return children
+}
+
+// Walk the node subtree in document order. The node itself is visited first.
+// Return false from the fn to stop walking.
+func (n *Node) Walk(fn func(*Node) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if !child.Walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// All nodes of the subtree in document order excluding the node itself
+func (n *Node) Descendants() []*Node {
+	var descendants []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		child.Walk(func(node *Node) bool {
+			descendants = append(descendants, node)
+			return true
+		})
+	}
+	return descendants
+}
+
+// Find the first node of the subtree, including the node itself, which satisfy the match
+func (n *Node) Find(match func(*Node) bool) *Node {
+	var result *Node
+	n.Walk(func(node *Node) bool {
+		if match(node) {
+			result = node
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// Find all nodes of the subtree, including the node itself, which satisfy the match
+func (n *Node) FindAll(match func(*Node) bool) []*Node {
+	var result []*Node
+	n.Walk(func(node *Node) bool {
+		if match(node) {
+			result = append(result, node)
+		}
+		return true
+	})
+	return result
+}
+
+// Find the node itself or the nearest ancestor which satisfy the match
+func (n *Node) Closest(match func(*Node) bool) *Node {
+	for node := n; node != nil; node = node.Parent {
+		if match(node) {
+			return node
+		}
+	}
+	return nil
+}
+
+// Concatenated content of all text nodes of the subtree
+func (n *Node) Text() string {
+	if n.Type == TEXT_NODE {
+		return n.Content
+	}
+
+	var text strings.Builder
+	n.Walk(func(node *Node) bool {
+		if node.Type == TEXT_NODE {
+			text.WriteString(node.Content)
+		}
+		return true
+	})
+	return text.String()
+}
+
+func ByName(name string) func(*Node) bool {
+	return func(node *Node) bool {
+		return node.IsElement() && strings.EqualFold(node.Name, name)
+	}
+}
+
+func ByClass(classSelectors ...string) func(*Node) bool {
+	return func(node *Node) bool {
+		return node.IsElement() && ContainsClass(node.Attr("class"), classSelectors)
+	}
+}
@@ -2,66 +2,146 @@ package parser
 
 import (
 	"io"
+	"runtime"
 	"sync"
 
 	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
 )
 
-func Parse(file io.Reader, selectors ...Selector) {
+// Selectors count from which they are driven by the pool of goroutines instead of the parse loop.
+// Measured by BenchmarkParseSequential and BenchmarkParseParallel over the saved pages of testdata.
+const PARALLEL_DISPATCH_THRESHOLD = 32
+
+type ParseOptions struct {
+	// Keep the character references like `&amp;` or `&#8217;` undecoded. Useful for debugging.
+	RawEntities bool
+}
+
+// Pass the token to each selector one by one
+func dispatchToken(selectors []Selector, t any) {
+	switch t := t.(type) {
+	case token.OpenTag:
+		node := Node{Name: t.Name, Type: OPEN_NODE, Tag: t}
+		for _, selector := range selectors {
+			selector.OnOpen(node)
+		}
+
+	case token.CloseTag:
+		node := Node{Name: t.Name, Type: CLOSE_NODE}
+		for _, selector := range selectors {
+			// Selectors must see each closing tag even without pending node.
+			// Some of them track the whole document structure.
+			selector.OnClose(node)
+		}
+
+	case token.Text:
+		// The text node is created only when some selector is building the tree
+		var textNode *Node
+		for _, selector := range selectors {
+			if selector.GetPendingNode() == nil {
+				continue
+			}
+			// Create separated text node may be more error resistant.
+			// That may prevent losing the text if something goes wrong.
+			if textNode == nil {
+				textNode = &Node{Name: string(TEXT_NODE), Type: TEXT_NODE, Content: string(token.RemoveNewLine(t.Data))}
+			}
+			selector.OnOpen(*textNode)
+		}
+	}
+}
+
+// Split the selectors between the fixed number of goroutines.
+// Each selector is always driven by the same goroutine, so selectors don't need synchronization.
+type selectorPool struct {
+	shards [][]Selector
+	tokens []chan any
+	wg     sync.WaitGroup
+}
+
+func (p *selectorPool) dispatch(t any) {
+	p.wg.Add(len(p.shards))
+	for _, tokens := range p.tokens {
+		tokens <- t
+	}
+	p.wg.Wait()
+}
+
+func (p *selectorPool) stop() {
+	for _, tokens := range p.tokens {
+		close(tokens)
+	}
+}
+
+func newSelectorPool(selectors []Selector, size int) *selectorPool {
+	pool := &selectorPool{}
+
+	shardSize := (len(selectors) + size - 1) / size
+	for start := 0; start < len(selectors); start += shardSize {
+		end := min(start+shardSize, len(selectors))
+		pool.shards = append(pool.shards, selectors[start:end])
+	}
+
+	for _, shard := range pool.shards {
+		tokens := make(chan any)
+		pool.tokens = append(pool.tokens, tokens)
+
+		go func(shard []Selector) {
+			for t := range tokens {
+				dispatchToken(shard, t)
+				pool.wg.Done()
+			}
+		}(shard)
+	}
+
+	return pool
+}
+
+// Parse the document and pass its nodes to the selectors.
+// Returns the non-fatal problems of the document, the parser recover from them.
+func Parse(file io.Reader, selectors ...Selector) Diagnostics {
+	return ParseWithOptions(file, ParseOptions{}, selectors...)
+}
+
+func ParseWithOptions(file io.Reader, options ParseOptions, selectors ...Selector) Diagnostics {
 	tok := NewTokenizer(file)
-	var wg sync.WaitGroup
+	tok.RawEntities = options.RawEntities
+	return parseTokens(tok, selectors)
+}
+
+func parseTokens(tok *Tokenizer, selectors []Selector) Diagnostics {
+	var tracker openTagsTracker
+
+	dispatch := func(t any) {
+		dispatchToken(selectors, t)
+	}
+	// The pool of the single goroutine only adds the hand-off of each token, see BenchmarkParseSequential
+	if procs := runtime.GOMAXPROCS(0); procs > 1 && len(selectors) >= PARALLEL_DISPATCH_THRESHOLD {
+		pool := newSelectorPool(selectors, procs)
+		defer pool.stop()
+		dispatch = pool.dispatch
+	}
 
 	for {
 		t := tok.Next()
 
-		if tok.Err != nil {
-			return
-		}
-
 		switch t := t.(type) {
-		case token.OpenTag:
-			node := Node{Name: t.Name, Type: OPEN_NODE, Tag: t}
-			for _, selector := range selectors {
-				wg.Add(1)
-				go func(selector Selector, node Node) {
-					defer wg.Done()
-					selector.OnOpen(node)
-				}(selector, node)
+		case TokenType:
+			if t == ERROR_TOKEN {
+				tracker.end(tok)
+				return tok.Diagnostics
 			}
-			wg.Wait()
+
+		case token.OpenTag:
+			tracker.open(tok, t)
+			dispatch(t)
 
 		case token.CloseTag:
-			node := Node{Name: t.Name, Type: CLOSE_NODE}
-			for _, selector := range selectors {
-				wg.Add(1)
-				go func(selector Selector, node Node) {
-					defer wg.Done()
-					if selector.GetPendingNode() == nil {
-						return
-					}
-					selector.OnClose(node)
-				}(selector, node)
-			}
-			wg.Wait()
+			tracker.close(tok, t)
+			dispatch(t)
 
 		case token.Text:
-			for _, selector := range selectors {
-				wg.Add(1)
-				go func(selector Selector, text token.Text) {
-					defer wg.Done()
-					pendingNode := selector.GetPendingNode()
-					if pendingNode == nil {
-						return
-					}
-					// Also that approach may be used.
-					// pendingNode.Content += string(removeNewLine(text.Data))
-
-					// Create separated text node may be more error resistant.
-					// That may prevent losing the text if something goes wrong.
-					selector.OnOpen(Node{Name: string(TEXT_NODE), Type: TEXT_NODE, Content: string(token.RemoveNewLine(text.Data))})
-				}(selector, t)
-			}
-			wg.Wait()
+			dispatch(t)
 
 		case token.Comment:
 		}
@@ -0,0 +1,115 @@
+package parser_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/selector"
+)
+
+var benchQueries = []string{
+	"a.article-button",
+	"ol.news > li",
+	"time",
+	"div.article-body p",
+	"h1",
+	"meta[property]",
+	"script[type='application/ld+json']",
+	"nav a.next",
+}
+
+// Selectors of the queries repeated up to the count, so the count picks the sequential or the parallel dispatch
+func benchSelectors(b *testing.B, count int) []parser.Selector {
+	selectors := make([]parser.Selector, 0, count)
+	for idx := 0; idx < count; idx++ {
+		s, err := selector.NewCssSelector(benchQueries[idx%len(benchQueries)], func(*parser.Node) {})
+		if err != nil {
+			b.Fatal(err)
+		}
+		selectors = append(selectors, s)
+	}
+	return selectors
+}
+
+// Report the throughput by the page size and the allocations per megabyte of the parsed pages.
+// The selectors are built outside of the measurement, they keep the state of the document so each parse has its own.
+func benchParse(b *testing.B, page []byte, selectorsCount int) {
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+
+	var mallocs uint64
+	var before, after runtime.MemStats
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		selectors := benchSelectors(b, selectorsCount)
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		parser.Parse(bytes.NewReader(page), selectors...)
+
+		b.StopTimer()
+		runtime.ReadMemStats(&after)
+		mallocs += after.Mallocs - before.Mallocs
+		b.StartTimer()
+	}
+
+	megabytes := float64(b.N) * float64(len(page)) / (1 << 20)
+	b.ReportMetric(float64(mallocs)/megabytes, "allocs/MB")
+}
+
+func readPage(b *testing.B, name string) []byte {
+	page, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return page
+}
+
+var benchPages = []string{"article.html", "listing.html"}
+
+// Selector counts around the parser.PARALLEL_DISPATCH_THRESHOLD
+var benchSelectorCounts = []int{1, 8, parser.PARALLEL_DISPATCH_THRESHOLD, 128}
+
+// Each selector count is parsed by the sequential dispatch of the parse loop
+// and by the pool of goroutines, so the results show where the pool pays off
+func benchDispatch(b *testing.B, procs int) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+	for _, name := range benchPages {
+		page := readPage(b, name)
+		for _, count := range benchSelectorCounts {
+			b.Run(fmt.Sprintf("%s/selectors=%d", name, count), func(b *testing.B) {
+				benchParse(b, page, count)
+			})
+		}
+	}
+}
+
+// The single proc always drives the selectors by the parse loop
+func BenchmarkParseSequential(b *testing.B) {
+	benchDispatch(b, 1)
+}
+
+// The selectors from the parser.PARALLEL_DISPATCH_THRESHOLD are driven by the pool of goroutines
+func BenchmarkParseParallel(b *testing.B) {
+	benchDispatch(b, max(runtime.NumCPU(), 4))
+}
+
+func BenchmarkParseDocument(b *testing.B) {
+	for _, name := range benchPages {
+		page := readPage(b, name)
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(page)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseDocument(bytes.NewReader(page)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
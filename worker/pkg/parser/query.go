@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/css"
+)
+
+// The templates query the same selectors for each page, so they are compiled once
+var compiledQueries sync.Map
+
+func compileQuery(query string) (*css.Selector, error) {
+	if compiled, ok := compiledQueries.Load(query); ok {
+		return compiled.(*css.Selector), nil
+	}
+
+	compiled, err := css.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	compiledQueries.Store(query, compiled)
+	return compiled, nil
+}
+
+// View of the tree node for the css selector matching
+type nodeElement struct {
+	node *Node
+}
+
+func (e nodeElement) Name() string {
+	return e.node.Name
+}
+
+func (e nodeElement) Attr(key string) (string, bool) {
+	value, ok := e.node.Tag.Attr[key]
+	return value, ok
+}
+
+func (e nodeElement) Parent() css.Element {
+	// The document node is not an element
+	if e.node.Parent == nil || !e.node.Parent.IsElement() {
+		return nil
+	}
+	return nodeElement{node: e.node.Parent}
+}
+
+func (e nodeElement) PrevSibling() css.Element {
+	for sibling := e.node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.IsElement() {
+			return nodeElement{node: sibling}
+		}
+	}
+	return nil
+}
+
+func (e nodeElement) Index() int {
+	index := 1
+	for sibling := e.node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.IsElement() {
+			index++
+		}
+	}
+	return index
+}
+
+func (e nodeElement) TypeIndex() int {
+	typeIndex := 1
+	for sibling := e.node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.IsElement() && strings.EqualFold(sibling.Name, e.node.Name) {
+			typeIndex++
+		}
+	}
+	return typeIndex
+}
+
+var _ css.Element = nodeElement{}
+
+// Match the compiled css selector against the node. Text and document nodes never match.
+func (n *Node) MatchSelector(selector *css.Selector) bool {
+	return n.IsElement() && selector.Match(nodeElement{node: n})
+}
+
+// Match the css selector against the node
+func (n *Node) Matches(query string) (bool, error) {
+	selector, err := compileQuery(query)
+	if err != nil {
+		return false, err
+	}
+	return n.MatchSelector(selector), nil
+}
+
+// First descendant element which match the css selector.
+//
+// Like the DOM querySelector the whole tree is visible for the combinators,
+// so `div p` match the `p` inside the node even when the `div` is above the node.
+func (n *Node) Query(query string) (*Node, error) {
+	selector, err := compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Node
+	for child := n.FirstChild; child != nil && result == nil; child = child.NextSibling {
+		result = child.Find(func(node *Node) bool {
+			return node.MatchSelector(selector)
+		})
+	}
+	return result, nil
+}
+
+// All descendant elements which match the css selector in document order
+func (n *Node) QueryAll(query string) ([]*Node, error) {
+	selector, err := compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		result = append(result, child.FindAll(func(node *Node) bool {
+			return node.MatchSelector(selector)
+		})...)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Content of these elements is the text as is, so it's written without escaping
+func isRawTextElement(name string) bool {
+	switch Lexeme(strings.ToLower(name)) {
+	case SCRIPT, STYLE, NOSCRIPT:
+		return true
+	}
+	return false
+}
+
+func renderOpenTag(out *strings.Builder, node *Node) {
+	out.WriteByte('<')
+	out.WriteString(node.Name)
+
+	// The attributes are stored in the map, sort them to keep the output stable
+	keys := make([]string, 0, len(node.Tag.Attr))
+	for key := range node.Tag.Attr {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		out.WriteByte(' ')
+		out.WriteString(key)
+		if value := node.Tag.Attr[key]; value != "" {
+			out.WriteString(`="`)
+			out.WriteString(html.EscapeString(value))
+			out.WriteByte('"')
+		}
+	}
+
+	if node.Tag.SelfClosing {
+		out.WriteString(" /")
+	}
+	out.WriteByte('>')
+}
+
+func renderNode(out *strings.Builder, node *Node) {
+	switch node.Type {
+	case TEXT_NODE:
+		if node.Parent != nil && isRawTextElement(node.Parent.Name) {
+			out.WriteString(node.Content)
+		} else {
+			out.WriteString(html.EscapeString(node.Content))
+		}
+
+	case OPEN_NODE:
+		renderOpenTag(out, node)
+		if node.IsLeaf() {
+			return
+		}
+		renderChildren(out, node)
+		out.WriteString("</")
+		out.WriteString(node.Name)
+		out.WriteByte('>')
+
+	case DOCUMENT_NODE:
+		renderChildren(out, node)
+	}
+}
+
+func renderChildren(out *strings.Builder, node *Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		renderNode(out, child)
+	}
+}
+
+// Markup of the node children. Text and attribute values are escaped, attributes are sorted by the name.
+func (n *Node) InnerHTML() string {
+	var out strings.Builder
+	renderChildren(&out, n)
+	return out.String()
+}
+
+// Markup of the node itself and its children
+func (n *Node) OuterHTML() string {
+	var out strings.Builder
+	renderNode(&out, n)
+	return out.String()
+}
@@ -11,7 +11,10 @@ type ClassSelector struct {
 func (s *ClassSelector) OnOpen(node parser.Node) {
 	if s.ast.IsBuilding() {
 		s.ast.AppendOpenTag(node)
-		return
+		// The node may close the tree by the implied end tag, then it's matched by itself
+		if s.ast.IsBuilding() {
+			return
+		}
 	}
 
 	if len(s.classes) > 0 {
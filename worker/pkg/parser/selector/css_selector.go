@@ -0,0 +1,189 @@
+package selector
+
+import (
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/css"
+)
+
+// Open tag which is tracked for the css selector combinators and structural pseudo-classes
+type cssFrame struct {
+	name        string
+	attr        map[string]string
+	parent      *cssFrame
+	prevSibling *cssFrame
+	index       int
+
+	// Children bookkeeping
+	lastChild     *cssFrame
+	childrenCount int
+}
+
+func (f *cssFrame) Name() string {
+	return f.name
+}
+
+func (f *cssFrame) Attr(key string) (string, bool) {
+	value, ok := f.attr[key]
+	return value, ok
+}
+
+func (f *cssFrame) Parent() css.Element {
+	// The document frame is not an element
+	if f.parent == nil || f.parent.parent == nil {
+		return nil
+	}
+	return f.parent
+}
+
+func (f *cssFrame) PrevSibling() css.Element {
+	if f.prevSibling == nil {
+		return nil
+	}
+	return f.prevSibling
+}
+
+func (f *cssFrame) Index() int {
+	return f.index
+}
+
+// Counted on demand, only the `:nth-of-type` needs it
+func (f *cssFrame) TypeIndex() int {
+	typeIndex := 1
+	for sibling := f.prevSibling; sibling != nil; sibling = sibling.prevSibling {
+		if sibling.name == f.name {
+			typeIndex++
+		}
+	}
+	return typeIndex
+}
+
+func (f *cssFrame) appendChild(name string, attr map[string]string) *cssFrame {
+	f.childrenCount++
+
+	child := &cssFrame{
+		name:        name,
+		attr:        attr,
+		parent:      f,
+		prevSibling: f.lastChild,
+		index:       f.childrenCount,
+	}
+	f.lastChild = child
+	return child
+}
+
+var _ css.Element = (*cssFrame)(nil)
+
+// CssSelector select the nodes by the css selector.
+//
+// Unlike the ClassSelector it must see the whole document,
+// it keep own stack of open tags to resolve combinators and structural pseudo-classes.
+type CssSelector struct {
+	ast            *parser.AstGenerator
+	selector       *css.Selector
+	frames         []*cssFrame
+	treeCompleteFn func(*parser.Node)
+	// Index of the frame of the pending tree root
+	rootFrame int
+}
+
+func (s *CssSelector) currentFrame() *cssFrame {
+	return s.frames[len(s.frames)-1]
+}
+
+// Pop the frames from the idx. The pending tree is completed when its root is popped.
+func (s *CssSelector) popFrames(idx int) bool {
+	// Closed tags can't get new children, release their subtrees
+	for _, frame := range s.frames[idx:] {
+		frame.lastChild = nil
+	}
+	s.frames = s.frames[:idx]
+
+	if s.ast.IsBuilding() && idx <= s.rootFrame {
+		s.ast.Complete()
+		return true
+	}
+	return false
+}
+
+func (s *CssSelector) OnOpen(node parser.Node) {
+	if node.Type == parser.TEXT_NODE {
+		if s.ast.IsBuilding() {
+			s.ast.AppendOpenTag(node)
+		}
+		return
+	}
+
+	name := strings.ToLower(node.Name)
+	// Close the tags which end tag is implied by the node, like the previous `<li>`
+	idx := len(s.frames)
+	for idx > 1 && parser.IsImpliedEndTag(s.frames[idx-1].name, name) {
+		idx--
+	}
+	if idx < len(s.frames) {
+		s.popFrames(idx)
+	}
+
+	frame := s.currentFrame().appendChild(name, node.Tag.Attr)
+	// Leaf is never closed, so it's not an open tag
+	if !node.IsLeaf() {
+		s.frames = append(s.frames, frame)
+	}
+
+	if s.ast.IsBuilding() {
+		s.ast.AppendOpenTag(node)
+		return
+	}
+
+	if s.selector.Match(frame) {
+		s.rootFrame = len(s.frames) - 1
+		s.ast.AppendOpenTag(node)
+	}
+}
+
+func (s *CssSelector) OnClose(node parser.Node) {
+	name := strings.ToLower(node.Name)
+
+	// Close the tags which lost the closing tag together with the closing node.
+	// If there is no open tag with such name the closing tag is ignored.
+	// The closing tag of the ancestor completes the pending tree, like the `</ul>` of the unclosed `<li>`.
+	for idx := len(s.frames) - 1; idx > 0; idx-- {
+		if s.frames[idx].name != name {
+			continue
+		}
+		if s.popFrames(idx) {
+			return
+		}
+		break
+	}
+
+	s.ast.CloseTag(node)
+}
+
+func (s *CssSelector) GetPendingNode() *parser.Node {
+	return s.ast.PendingNode()
+}
+
+func (s *CssSelector) onTreeComplete(node *parser.Node) {
+	s.ast.Free()
+	s.treeCompleteFn(node)
+}
+
+var _ parser.Selector = (*CssSelector)(nil)
+
+func NewCssSelector(selector string, treeCompleteFn func(node *parser.Node)) (*CssSelector, error) {
+	compiled, err := css.Compile(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	cssSelector := &CssSelector{
+		ast:            parser.NewAstGenerator(),
+		selector:       compiled,
+		frames:         []*cssFrame{{}},
+		treeCompleteFn: treeCompleteFn,
+	}
+	cssSelector.ast.OnTreeComplete(cssSelector.onTreeComplete)
+	return cssSelector, nil
+}
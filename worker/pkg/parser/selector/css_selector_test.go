@@ -0,0 +1,78 @@
+package selector
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+func TestCssSelectorUnclosedTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		source   string
+		texts    []string
+	}{
+		{
+			name:     "li closed by the next li and the list",
+			selector: "ul li",
+			source:   `<ul><li>a</li><li>b<li>c</ul><p>after</p>`,
+			texts:    []string{"a", "b", "c"},
+		},
+		{
+			name:     "p closed by the parent",
+			selector: "div > p",
+			source:   `<div><p>a<p>b</div><span>after</span>`,
+			texts:    []string{"a", "b"},
+		},
+		{
+			name:     "p closed by the block",
+			selector: "p",
+			source:   `<body><p>a<div>b</div><p>c</body>`,
+			texts:    []string{"a", "c"},
+		},
+		{
+			name:     "nested list keeps the outer li open",
+			selector: "ul.top > li",
+			source:   `<ul class="top"><li>a<ul><li>b<li>c</ul><li>d</ul>`,
+			texts:    []string{"abc", "d"},
+		},
+		{
+			name:     "table cells",
+			selector: "td",
+			source:   `<table><tr><td>a<td>b<tr><td>c</table>`,
+			texts:    []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var texts []string
+			selector, err := NewCssSelector(tt.selector, func(node *parser.Node) {
+				texts = append(texts, node.Text())
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parser.Parse(strings.NewReader(tt.source), selector)
+			if !slices.Equal(texts, tt.texts) {
+				t.Errorf("got %q, want %q", texts, tt.texts)
+			}
+		})
+	}
+}
+
+func TestClassSelectorUnclosedTags(t *testing.T) {
+	var texts []string
+	selector := NewClassSelector([]string{"item"}, func(node *parser.Node) {
+		texts = append(texts, node.Text())
+	})
+
+	parser.Parse(strings.NewReader(`<ul><li class="item">a<li class="item">b</li></ul>`), selector)
+	if want := []string{"a", "b"}; !slices.Equal(texts, want) {
+		t.Errorf("got %q, want %q", texts, want)
+	}
+}
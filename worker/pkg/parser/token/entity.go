@@ -0,0 +1,21 @@
+package token
+
+import (
+	"bytes"
+	"html"
+)
+
+// Decode the named character references of the HTML5 table and the decimal/hex numeric references.
+//
+// Example: `&quot;News&quot; &#8212; &amp;nbsp;` become `"News" — &nbsp;`
+// The source is returned as is when there is nothing to decode.
+func DecodeEntities(source []byte) []byte {
+	if bytes.IndexByte(source, '&') == -1 {
+		return source
+	}
+	return []byte(html.UnescapeString(string(source)))
+}
+
+func DecodeEntitiesString(source string) string {
+	return html.UnescapeString(source)
+}
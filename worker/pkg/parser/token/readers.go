@@ -6,15 +6,14 @@ type TokenCursorReader struct {
 	Err    error
 }
 
+// Read the next byte. At the end of data the Err is set and zero is returned.
 func (r *TokenCursorReader) Byte() TerminalSymbol {
-	symbol := r.data[r.cursor.End]
-
-	if r.cursor.End > len(r.data) {
+	if r.cursor.End >= len(r.data) {
 		r.Err = ErrCursorEnd
-		r.cursor.End = len(r.data) - 1
-		return symbol
+		return 0
 	}
 
+	symbol := r.data[r.cursor.End]
 	r.cursor.End++
 	return symbol
 }
@@ -23,7 +22,11 @@ func (r *TokenCursorReader) Data() []byte {
 	return r.data[r.cursor.Start:r.cursor.End]
 }
 
+// Return back the last read byte. After the end of data there is nothing to return.
 func (r *TokenCursorReader) Backward() {
+	if r.Err != nil {
+		return
+	}
 	r.cursor.End--
 }
 
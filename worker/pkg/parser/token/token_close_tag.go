@@ -10,16 +10,19 @@ func (t *CloseTag) unmarshalName(currReader *TokenCursorReader) {
 loop:
 	for {
 		symbol := currReader.Byte()
+		if currReader.Err != nil {
+			break
+		}
 
 		switch symbol {
-		case R_BRACKET:
+		case R_BRACKET, SPACE, NEW_LINE, C_RETURN, TAB, FORM_FEED:
 			currReader.Backward()
 			break loop
 		default:
 		}
 	}
 
-	t.Name = string(currReader.Data())
+	t.Name = InternName(currReader.Data())
 }
 
 func (t *CloseTag) Unmarshal(data []byte) (err error) {
@@ -30,10 +33,13 @@ func (t *CloseTag) Unmarshal(data []byte) (err error) {
 	}
 
 	if symbol := currReader.Byte(); symbol != SLASH {
-		return ErrMssingLeftBracket
+		return ErrMssingSlash
 	}
 
 	t.unmarshalName(currReader)
+	if currReader.Err != nil {
+		return ErrMissingRightBracket
+	}
 
 	return err
 }
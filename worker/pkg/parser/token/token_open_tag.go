@@ -1,88 +1,139 @@
 package token
 
+import "bytes"
+
 type OpenTag struct {
 	Name string
 	Attr map[string]string
+	// The tag is closed by the `/>` like `<img src="..."/>`
+	SelfClosing bool
 }
 
-func (t *OpenTag) unmarshalAttrKey(currReader *TokenCursorReader) {
+func isSpace(symbol TerminalSymbol) bool {
+	switch symbol {
+	case SPACE, NEW_LINE, C_RETURN, TAB, FORM_FEED:
+		return true
+	}
+	return false
+}
+
+func (t *OpenTag) skipSpaces(currReader *TokenCursorReader) {
 	for {
 		symbol := currReader.Byte()
-
-		switch symbol {
-		case SPACE, NEW_LINE, C_RETURN, TAB, FORM_FEED, SLASH:
-			currReader.Backward()
+		if currReader.Err != nil {
 			return
-		case EQUALS:
-			fallthrough
-		case R_BRACKET:
+		}
+		if !isSpace(symbol) {
 			currReader.Backward()
 			return
 		}
 	}
 }
 
-func (t *OpenTag) unmarshalAttrValue(currReader *TokenCursorReader) {
+func (t *OpenTag) unmarshalAttrKey(currReader *TokenCursorReader) {
 	for {
 		symbol := currReader.Byte()
-		if symbol != EQUALS {
-			currReader.Backward()
+		if currReader.Err != nil {
+			return
 		}
 
-		quote := currReader.Byte()
-
-		switch quote {
-		case R_BRACKET:
+		switch symbol {
+		case SPACE, NEW_LINE, C_RETURN, TAB, FORM_FEED, SLASH, EQUALS, R_BRACKET:
 			currReader.Backward()
 			return
-		case SINGLE_QUOTE, DOUBLE_QUOTE:
-			currReader.SetStart(currReader.Cursor().End)
+		}
+	}
+}
 
-			for {
-				symbol := currReader.Byte()
-				if symbol == quote {
-					currReader.Backward()
-					return
-				}
+func (t *OpenTag) unmarshalAttrValue(currReader *TokenCursorReader) error {
+	quote := currReader.Byte()
+	if currReader.Err != nil {
+		return nil
+	}
+
+	switch quote {
+	case SINGLE_QUOTE, DOUBLE_QUOTE:
+		currReader.SetStart(currReader.Cursor().End)
+
+		for {
+			symbol := currReader.Byte()
+			if currReader.Err != nil {
+				return ErrUnterminatedAttrValue
+			}
+			if symbol == quote {
+				currReader.Backward()
+				return nil
 			}
 		}
 
+	case R_BRACKET:
+		currReader.Backward()
+		return nil
+	}
+
+	// Unquoted value like `<a href=/news>`
+	for {
+		symbol := currReader.Byte()
+		if currReader.Err != nil {
+			return nil
+		}
+
+		if isSpace(symbol) || symbol == R_BRACKET {
+			currReader.Backward()
+			return nil
+		}
 	}
 }
 
-func (t *OpenTag) unmarshalAttr(currReader *TokenCursorReader) {
+func (t *OpenTag) unmarshalAttr(currReader *TokenCursorReader) (err error) {
 	for {
-		currReader.SetStart(currReader.Cursor().End)
+		t.skipSpaces(currReader)
 
 		symbol := currReader.Byte()
-		if symbol == '>' {
-			return
+		if currReader.Err != nil || symbol == R_BRACKET {
+			return err
 		}
-
-		if symbol == SPACE {
-			currReader.SetStart(currReader.Cursor().Start + 1)
+		if symbol == SLASH {
+			continue
 		}
+		currReader.Backward()
 
-		t.unmarshalAttrKey(currReader)
-		attrKey := string(RemoveNewLine(currReader.Data()))
 		currReader.SetStart(currReader.Cursor().End)
+		t.unmarshalAttrKey(currReader)
+		attrKey := string(bytes.ToLower(RemoveNewLine(currReader.Data())))
 
-		t.unmarshalAttrValue(currReader)
-		attrValue := string(RemoveNewLine(currReader.Data()))
+		t.skipSpaces(currReader)
 
-		quote := currReader.Byte()
+		var attrValue string
+		if symbol := currReader.Byte(); currReader.Err == nil {
+			if symbol == EQUALS {
+				t.skipSpaces(currReader)
+				currReader.SetStart(currReader.Cursor().End)
 
-		switch quote {
-		case SINGLE_QUOTE, DOUBLE_QUOTE:
-		default:
-			currReader.Backward()
+				if valueErr := t.unmarshalAttrValue(currReader); valueErr != nil && err == nil {
+					err = valueErr
+				}
+				attrValue = string(RemoveNewLine(currReader.Data()))
+
+				// Skip the closing quote
+				switch quote := currReader.Byte(); quote {
+				case SINGLE_QUOTE, DOUBLE_QUOTE:
+				default:
+					currReader.Backward()
+				}
+			} else {
+				currReader.Backward()
+			}
 		}
 
-		if attrKey == "" || attrValue == "" {
+		if attrKey == "" {
 			continue
 		}
 
-		t.Attr[attrKey] = attrValue
+		// The first attribute wins like in the browsers
+		if _, exists := t.Attr[attrKey]; !exists {
+			t.Attr[attrKey] = attrValue
+		}
 	}
 }
 
@@ -92,6 +143,9 @@ func (t *OpenTag) unmarshalName(curReader *TokenCursorReader) {
 loop:
 	for {
 		symbol := curReader.Byte()
+		if curReader.Err != nil {
+			break
+		}
 
 		switch symbol {
 		case SLASH, R_BRACKET, SPACE, NEW_LINE, C_RETURN, TAB, FORM_FEED:
@@ -101,7 +155,7 @@ loop:
 		}
 	}
 
-	t.Name = string(curReader.Data())
+	t.Name = InternName(curReader.Data())
 }
 
 func (t *OpenTag) Unmarshal(data []byte) (err error) {
@@ -112,13 +166,21 @@ func (t *OpenTag) Unmarshal(data []byte) (err error) {
 	}
 
 	t.unmarshalName(currReader)
+	if currReader.Err != nil {
+		return ErrMissingRightBracket
+	}
 	if currReader.Cursor().End == currReader.Len() {
 		return nil
 	}
 
 	t.Attr = make(map[string]string)
 
-	t.unmarshalAttr(currReader)
+	if err = t.unmarshalAttr(currReader); err != nil {
+		return err
+	}
+	if currReader.Err != nil {
+		return ErrMissingRightBracket
+	}
 
-	return err
+	return nil
 }
@@ -27,7 +27,9 @@ const (
 )
 
 var (
-	ErrMssingLeftBracket = errors.New("missing left bracket")
-	ErrMssingSlash       = errors.New("missing slash")
-	ErrCursorEnd         = errors.New("end of cursor")
+	ErrMssingLeftBracket     = errors.New("missing left bracket")
+	ErrMssingSlash           = errors.New("missing slash")
+	ErrCursorEnd             = errors.New("end of cursor")
+	ErrMissingRightBracket   = errors.New("missing right bracket")
+	ErrUnterminatedAttrValue = errors.New("unterminated attribute value")
 )
@@ -2,11 +2,60 @@ package token
 
 import "bytes"
 
+// Remove the tabs, new lines and pairs of spaces which come from the html formatting.
+//
+// The source is returned as is when there is nothing to remove,
+// otherwise the result is allocated once.
 func RemoveNewLine(source []byte) []byte {
-	source = bytes.Replace(source, []byte("  "), []byte(""), -1)
-	source = bytes.Replace(source, []byte("\t"), []byte(""), -1)
-	source = bytes.Replace(source, []byte{'\n'}, []byte{}, -1)
-	source = bytes.Replace(source, []byte{'\r'}, []byte{}, -1)
-	source = bytes.Replace(source, []byte("\r\n"), []byte{}, -1)
-	return source
+	if bytes.IndexAny(source, "\t\n\r") == -1 && !bytes.Contains(source, []byte("  ")) {
+		return source
+	}
+
+	result := make([]byte, 0, len(source))
+	for idx := 0; idx < len(source); idx++ {
+		switch symbol := source[idx]; symbol {
+		case TAB, NEW_LINE, C_RETURN:
+		case SPACE:
+			// The pairs of spaces are removed, so the odd space of the run is kept
+			run := 1
+			for idx+1 < len(source) && source[idx+1] == SPACE {
+				run++
+				idx++
+			}
+			if run%2 == 1 {
+				result = append(result, SPACE)
+			}
+		default:
+			result = append(result, symbol)
+		}
+	}
+	return result
+}
+
+// Names of the common tags. The tag name is taken from there to not allocate it for each tag.
+var KNOWN_TAG_NAMES = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"a", "abbr", "address", "area", "article", "aside", "audio", "b", "base", "blockquote",
+		"body", "br", "button", "caption", "cite", "code", "col", "colgroup", "dd", "del",
+		"details", "div", "dl", "dt", "em", "embed", "fieldset", "figcaption", "figure", "footer",
+		"form", "h1", "h2", "h3", "h4", "h5", "h6", "head", "header", "hr",
+		"html", "i", "iframe", "img", "input", "ins", "label", "legend", "li", "link",
+		"main", "mark", "meta", "nav", "noscript", "ol", "option", "p", "path", "picture",
+		"pre", "q", "s", "script", "section", "select", "small", "source", "span", "strong",
+		"style", "sub", "summary", "sup", "svg", "table", "tbody", "td", "template", "textarea",
+		"tfoot", "th", "thead", "time", "title", "tr", "track", "u", "ul", "use",
+		"video", "wbr",
+	} {
+		KNOWN_TAG_NAMES[name] = name
+	}
+}
+
+// Convert the tag name to the string without allocation for the common tags
+func InternName(name []byte) string {
+	if interned, ok := KNOWN_TAG_NAMES[string(name)]; ok {
+		return interned
+	}
+	return string(name)
 }
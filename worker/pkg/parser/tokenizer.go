@@ -1,20 +1,41 @@
 package parser
 
 import (
+	"bytes"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
 )
 
+// Initial size of the read buffer. It grows when the token doesn't fit.
+const TOKENIZER_BUFFER_SIZE = 4096
+
 type Tokenizer struct {
 	source io.Reader
 	buf    []byte
-	Err    error
+	// Set when there is nothing to read anymore. Normally it's the io.EOF
+	Err error
+	// Error of the source which is returned together with the last bytes
+	readErr error
 
 	reader token.Cursor
 	data   token.Cursor
 	tt     TokenType
 	state  ParserState
+	// Name of the raw text or RCDATA element which content is read
+	rawTag string
+
+	// Position of the buf start in the document
+	offset    int
+	line      int
+	lineStart int
+
+	// Keep the character references of text and attribute values as is
+	RawEntities bool
+	// Non-fatal problems of the document
+	Diagnostics Diagnostics
 }
 
 func (tok *Tokenizer) GetBuffer() ([]byte, int) {
@@ -33,11 +54,26 @@ func (tok *Tokenizer) GetBuffer() ([]byte, int) {
 	return buf, numElems
 }
 
+// Remember the position of the bytes which are dropped from the buffer
+func (tok *Tokenizer) advanceOffset(dropped []byte) {
+	if newLines := bytes.Count(dropped, []byte{'\n'}); newLines > 0 {
+		tok.line += newLines
+		tok.lineStart = tok.offset + bytes.LastIndexByte(dropped, '\n') + 1
+	}
+	tok.offset += len(dropped)
+}
+
 func (tok *Tokenizer) readByte() byte {
-	if tok.reader.End >= len(tok.buf) {
+	for tok.reader.End >= len(tok.buf) {
 		if tok.Err != nil {
 			return 0
 		}
+		if tok.readErr != nil {
+			tok.Err = tok.readErr
+			return 0
+		}
+
+		tok.advanceOffset(tok.buf[:tok.reader.Start])
 
 		buf, numElems := tok.GetBuffer()
 
@@ -49,11 +85,7 @@ func (tok *Tokenizer) readByte() byte {
 		tok.reader.Start, tok.reader.End, tok.buf = 0, numElems, buf[:numElems]
 
 		var n int
-		n, tok.Err = tok.source.Read(buf[numElems:cap(buf)])
-		if n == 0 {
-			return 0
-		}
-
+		n, tok.readErr = tok.source.Read(buf[numElems:cap(buf)])
 		tok.buf = buf[:numElems+n]
 	}
 
@@ -62,10 +94,78 @@ func (tok *Tokenizer) readByte() byte {
 	return b
 }
 
-func (tok *Tokenizer) tag() {
+// Offset from the document start, line and column of the buffer position
+func (tok *Tokenizer) position(pos int) (offset, line, column int) {
+	offset = tok.offset + pos
+	line = tok.line + 1
+	column = offset - tok.lineStart + 1
+
+	if before := tok.buf[:pos]; len(before) > 0 {
+		if newLines := bytes.Count(before, []byte{'\n'}); newLines > 0 {
+			line += newLines
+			column = pos - bytes.LastIndexByte(before, '\n')
+		}
+	}
+
+	return offset, line, column
+}
+
+// Position of the current token
+func (tok *Tokenizer) Position() (offset, line, column int) {
+	return tok.position(tok.reader.Start)
+}
+
+// Create the error at the current token position
+func (tok *Tokenizer) NewParseError(err error) *ParseError {
+	offset, line, column := tok.Position()
+
+	snippet := tok.buf[tok.reader.Start:tok.reader.End]
+	if len(snippet) > MAX_PARSE_ERROR_SNIPPET {
+		snippet = snippet[:MAX_PARSE_ERROR_SNIPPET]
+	}
+
+	return &ParseError{
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Snippet: string(snippet),
+		Err:     err,
+	}
+}
+
+func (tok *Tokenizer) AddDiagnostic(parseErr *ParseError) {
+	switch {
+	case len(tok.Diagnostics) < MAX_PARSE_DIAGNOSTICS:
+		tok.Diagnostics = append(tok.Diagnostics, parseErr)
+	case len(tok.Diagnostics) == MAX_PARSE_DIAGNOSTICS:
+		tok.Diagnostics = append(tok.Diagnostics, &ParseError{
+			Offset: parseErr.Offset,
+			Line:   parseErr.Line,
+			Column: parseErr.Column,
+			Err:    ErrTooManyDiagnostics,
+		})
+	}
+}
+
+func (tok *Tokenizer) report(err error) {
+	tok.AddDiagnostic(tok.NewParseError(err))
+}
+
+func (tok *Tokenizer) decodeEntities(data []byte) []byte {
+	if tok.RawEntities {
+		return data
+	}
+	return token.DecodeEntities(data)
+}
+
+// Read the tag until the `>` which is not inside the quoted attribute value.
+// Reports whether the tag is self-closing like `<br/>`.
+func (tok *Tokenizer) tag() (selfClosing bool) {
 	tok.data.Start = tok.reader.End - 2
 	tok.data.End = tok.reader.End
 
+	var quote, prevSymbol token.TerminalSymbol
+
 loop:
 	for {
 		var symbol token.TerminalSymbol = tok.readByte()
@@ -73,15 +173,32 @@ loop:
 			break
 		}
 
+		if quote != 0 {
+			if symbol == quote {
+				quote = 0
+			}
+			continue
+		}
+
 		switch symbol {
-		case token.SPACE:
+		case token.SPACE, token.NEW_LINE, token.C_RETURN, token.TAB, token.FORM_FEED:
 			continue
 
+		case token.SINGLE_QUOTE, token.DOUBLE_QUOTE:
+			if prevSymbol == token.EQUALS {
+				quote = symbol
+			}
+
 		case token.R_BRACKET:
 			tok.data.End = tok.reader.End
+			selfClosing = prevSymbol == token.SLASH
 			break loop
 		}
+
+		prevSymbol = symbol
 	}
+
+	return selfClosing
 }
 
 func (tok *Tokenizer) readUntilCloseBracket() {
@@ -101,6 +218,170 @@ func (tok *Tokenizer) readUntilCloseBracket() {
 	}
 }
 
+// Read until the terminator including it. Reports whether the terminator is found.
+func (tok *Tokenizer) readUntil(terminator []byte) bool {
+	for {
+		tok.readByte()
+		if tok.Err != nil {
+			tok.data.End = tok.reader.End
+			return false
+		}
+
+		if bytes.HasSuffix(tok.buf[tok.reader.Start:tok.reader.End], terminator) {
+			tok.data.End = tok.reader.End
+			return true
+		}
+	}
+}
+
+// Read ahead the expected bytes. On mismatch the read bytes are returned back.
+// The case insensitive comparison expects lower case bytes.
+//
+// The buffer may be moved while reading, so the position is kept relative to the token start.
+func (tok *Tokenizer) readAhead(expected []byte, caseInsensitive bool) bool {
+	mark := tok.reader.End - tok.reader.Start
+
+	for _, expectedSymbol := range expected {
+		symbol := tok.readByte()
+		if tok.Err != nil {
+			return false
+		}
+
+		if caseInsensitive && 'A' <= symbol && symbol <= 'Z' {
+			symbol += 'a' - 'A'
+		}
+
+		if symbol == expectedSymbol {
+			continue
+		}
+
+		tok.reader.End = tok.reader.Start + mark
+		return false
+	}
+
+	return true
+}
+
+// Markup declarations which start with `<!` or `<?`.
+// The `<![CDATA[...]]>` section is the text, others are treated as the comment.
+func (tok *Tokenizer) markupDeclaration() any {
+	switch {
+	case tok.readAhead([]byte("--"), false):
+		if !tok.readUntil([]byte("-->")) {
+			tok.report(ErrUnterminatedComment)
+		}
+
+	case tok.readAhead([]byte("[CDATA["), false):
+		dataStart := tok.reader.End - tok.reader.Start
+		if !tok.readUntil([]byte("]]>")) {
+			tok.report(ErrUnterminatedCDATA)
+		}
+
+		data := tok.buf[tok.reader.Start+dataStart : tok.reader.End]
+		data = bytes.TrimSuffix(data, []byte("]]>"))
+
+		tok.tt = TEXT_TOKEN
+		return token.Text{Data: data}
+
+	default:
+		tok.readUntilCloseBracket()
+		if tok.Err != nil {
+			tok.report(ErrUnterminatedComment)
+		}
+	}
+
+	tok.tt = COMMENT_TOKEN
+	return token.Comment{Data: tok.buf[tok.reader.Start:tok.reader.End]}
+}
+
+func (tok *Tokenizer) closeTag() any {
+	tok.tag()
+	if tok.Err != nil {
+		tok.report(ErrUnexpectedEOF)
+		tok.tt = ERROR_TOKEN
+		return tok.tt
+	}
+
+	bytes := tok.buf[tok.data.Start:tok.data.End]
+
+	tag := token.CloseTag{}
+	if err := tag.Unmarshal(bytes); err != nil {
+		tok.report(fmt.Errorf("%w: %w", ErrInvalidCloseTag, err))
+	}
+
+	tok.tt = CLOSE_TAG_TOKEN
+	return tag
+}
+
+// Check that `<` is followed by the closing tag of the current raw text element like `</script>`.
+func (tok *Tokenizer) isRawTextEndTag() bool {
+	mark := tok.reader.End - tok.reader.Start
+	defer func() {
+		tok.reader.End = tok.reader.Start + mark
+	}()
+
+	if !tok.readAhead([]byte{token.SLASH}, false) || !tok.readAhead([]byte(tok.rawTag), true) {
+		return false
+	}
+
+	symbol := tok.readByte()
+	if tok.Err != nil {
+		return false
+	}
+
+	switch symbol {
+	case token.SPACE, token.NEW_LINE, token.C_RETURN, token.TAB, token.FORM_FEED, token.SLASH, token.R_BRACKET:
+		return true
+	}
+	return false
+}
+
+// Content of the raw text and RCDATA elements is the text until the matching closing tag.
+// Any other tags inside it, like `"</div>"` string of the script, are part of the text.
+func (tok *Tokenizer) rawText() any {
+	for {
+		symbol := tok.readByte()
+		if tok.Err != nil {
+			break
+		}
+
+		if symbol != token.L_BRACKET || !tok.isRawTextEndTag() {
+			continue
+		}
+
+		if x := tok.reader.End - 1; tok.reader.Start < x {
+			tok.reader.End = x
+			return tok.rawTextToken()
+		}
+
+		tok.state = NORMAL
+		tok.rawTag = ""
+
+		// Skip the `/` of the closing tag
+		tok.readByte()
+		return tok.closeTag()
+	}
+
+	tok.report(ErrUnterminatedRawText)
+
+	if tok.reader.Start < tok.reader.End {
+		return tok.rawTextToken()
+	}
+
+	tok.tt = ERROR_TOKEN
+	return tok.tt
+}
+
+func (tok *Tokenizer) rawTextToken() any {
+	data := tok.buf[tok.reader.Start:tok.reader.End]
+	if tok.state == RCDATA {
+		data = tok.decodeEntities(data)
+	}
+
+	tok.tt = TEXT_TOKEN
+	return token.Text{Data: data}
+}
+
 func (tok *Tokenizer) Next() any {
 	tok.reader.Start = tok.reader.End
 	tok.data.Start = tok.reader.End
@@ -110,6 +391,10 @@ func (tok *Tokenizer) Next() any {
 		return tok.tt
 	}
 
+	if tok.state != NORMAL {
+		return tok.rawText()
+	}
+
 	for {
 		var symbol token.TerminalSymbol = tok.readByte()
 		if tok.Err != nil {
@@ -136,65 +421,71 @@ func (tok *Tokenizer) Next() any {
 			tokenType = CLOSE_TAG_TOKEN
 		case symbol == '!' || symbol == '?':
 			tokenType = COMMENT_TOKEN
-			tok.reader.End = tok.reader.End - 2
 		default:
 			tok.reader.End--
 			continue
 		}
 
-		if x := tok.reader.End - 2; tok.reader.Start < x && tokenType != COMMENT_TOKEN {
+		if x := tok.reader.End - 2; tok.reader.Start < x {
 			tok.reader.End = x
 			tok.data.End = x
 
 			tok.tt = TEXT_TOKEN
-			return token.Text{Data: tok.buf[tok.data.Start:tok.data.End]}
+			return token.Text{Data: tok.decodeEntities(tok.buf[tok.data.Start:tok.data.End])}
 		}
 
 		switch tokenType {
 		case COMMENT_TOKEN:
-			tok.readUntilCloseBracket()
-			return token.Comment{Data: tok.buf[tok.data.Start:tok.data.End]}
+			return tok.markupDeclaration()
 
 		case OPEN_TAG_TOKEN:
-
-			if tok.state != NORMAL {
-				tok.tt = SKIP_TOKEN
+			selfClosing := tok.tag()
+			if tok.Err != nil {
+				tok.report(ErrUnexpectedEOF)
+				tok.tt = ERROR_TOKEN
 				return tok.tt
 			}
-			tok.tag()
 
-			bytes := tok.buf[tok.data.Start:tok.data.End]
+			tagBytes := tok.buf[tok.data.Start:tok.data.End]
 
-			tag := token.OpenTag{}
-			_ = tag.Unmarshal(bytes)
+			tag := token.OpenTag{SelfClosing: selfClosing}
+			if err := tag.Unmarshal(tagBytes); err != nil {
+				tok.report(fmt.Errorf("%w: %w", ErrInvalidOpenTag, err))
+			}
+			if !tok.RawEntities && bytes.IndexByte(tagBytes, '&') != -1 {
+				for key, value := range tag.Attr {
+					tag.Attr[key] = token.DecodeEntitiesString(value)
+				}
+			}
 
-			switch Lexeme(tag.Name) {
-			case SCRIPT:
-				tok.state = SCRIPT_CONTENT
+			if !tag.SelfClosing {
+				switch name := strings.ToLower(tag.Name); Lexeme(name) {
+				case SCRIPT, STYLE, NOSCRIPT:
+					tok.state = RAW_TEXT
+					tok.rawTag = name
+				case TEXTAREA, TITLE:
+					tok.state = RCDATA
+					tok.rawTag = name
+				}
 			}
 
 			tok.tt = OPEN_TAG_TOKEN
-			return tag
-		case CLOSE_TAG_TOKEN:
-			tok.tag()
-			if tok.Err != nil {
-				tok.tt = ERROR_TOKEN
-				return tok.tt
+			if tag.SelfClosing {
+				tok.tt = SELF_CLOSING_TAG_TOKEN
 			}
-
-			bytes := tok.buf[tok.data.Start:tok.data.End]
-
-			tag := token.CloseTag{}
-			_ = tag.Unmarshal(bytes)
-
-			tok.state = NORMAL
-			tok.tt = CLOSE_TAG_TOKEN
-
 			return tag
-		default:
+
+		case CLOSE_TAG_TOKEN:
+			return tok.closeTag()
 		}
 	}
 
+	// The text at the end of the document
+	if tok.reader.Start < tok.reader.End {
+		tok.tt = TEXT_TOKEN
+		return token.Text{Data: tok.decodeEntities(tok.buf[tok.reader.Start:tok.reader.End])}
+	}
+
 	tok.tt = ERROR_TOKEN
 	return tok.tt
 }
@@ -202,6 +493,6 @@ func (tok *Tokenizer) Next() any {
 func NewTokenizer(reader io.Reader) *Tokenizer {
 	return &Tokenizer{
 		source: reader,
-		buf:    make([]byte, 0, 24),
+		buf:    make([]byte, 0, TOKENIZER_BUFFER_SIZE),
 	}
 }
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
+)
+
+// Text token of the data as it's rendered by the tokenize
+func textToken(data string) string {
+	return "text:" + data
+}
+
+// Tokens of the source like `<div`, `</div`, `text:...` and `comment:...`
+func tokenize(source io.Reader) ([]string, Diagnostics) {
+	tok := NewTokenizer(source)
+
+	var tokens []string
+	for {
+		switch t := tok.Next().(type) {
+		case TokenType:
+			if t == ERROR_TOKEN {
+				return tokens, tok.Diagnostics
+			}
+		case token.OpenTag:
+			tokens = append(tokens, "<"+strings.ToLower(t.Name))
+		case token.CloseTag:
+			tokens = append(tokens, "</"+strings.ToLower(t.Name))
+		case token.Text:
+			tokens = append(tokens, textToken(string(t.Data)))
+		case token.Comment:
+			tokens = append(tokens, "comment:"+string(t.Data))
+		}
+	}
+}
+
+func diagnosticErrors(diagnostics Diagnostics) []error {
+	var errs []error
+	for _, diagnostic := range diagnostics {
+		errs = append(errs, diagnostic.Err)
+	}
+	return errs
+}
+
+func TestTokenizer(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		tokens []string
+		errs   []error
+	}{
+		{
+			name:   "less than inside script",
+			source: `<script>if (a < b && c<d) { x = 1 }</script>`,
+			tokens: []string{"<script", "text:if (a < b && c<d) { x = 1 }", "</script"},
+		},
+		{
+			name:   "less than inside style",
+			source: `<style>a > b { color: red } /* <p> */</style>`,
+			tokens: []string{"<style", "text:a > b { color: red } /* <p> */", "</style"},
+		},
+		{
+			name:   "closing tag inside js string",
+			source: `<div><script>var s = "</div>"; var t = '</span>';</script></div>`,
+			tokens: []string{"<div", "<script", `text:var s = "</div>"; var t = '</span>';`, "</script", "</div"},
+		},
+		{
+			name:   "tag name prefix inside script",
+			source: `<script>a</scripts>b</script>`,
+			tokens: []string{"<script", "text:a</scripts>b", "</script"},
+		},
+		{
+			name:   "uppercase script end tag",
+			source: `<script>var a = 1;</SCRIPT><p>b</p>`,
+			tokens: []string{"<script", "text:var a = 1;", "</script", "<p", "text:b", "</p"},
+		},
+		{
+			name:   "script end tag with spaces",
+			source: `<script>x</script >y`,
+			tokens: []string{"<script", "text:x", "</script", "text:y"},
+		},
+		{
+			name:   "entities of title",
+			source: `<title>Tom &amp; Jerry &lt;b&gt; &#8212; &quot;news&quot;</title>`,
+			tokens: []string{"<title", `text:Tom & Jerry <b> — "news"`, "</title"},
+		},
+		{
+			name:   "tags inside textarea are text",
+			source: `<textarea>&lt;p&gt; <b>bold</b></textarea>`,
+			tokens: []string{"<textarea", "text:<p> <b>bold</b>", "</textarea"},
+		},
+		{
+			name:   "entities of script are kept",
+			source: `<script>a &amp;&amp; b</script>`,
+			tokens: []string{"<script", "text:a &amp;&amp; b", "</script"},
+		},
+		{
+			name:   "cdata section is text",
+			source: `<svg><![CDATA[a < b && </svg>]]></svg>`,
+			tokens: []string{"<svg", "text:a < b && </svg>", "</svg"},
+		},
+		{
+			name:   "comment with tags",
+			source: `<p>a<!-- <div> </p> -->b</p>`,
+			tokens: []string{"<p", "text:a", "comment:<!-- <div> </p> -->", "text:b", "</p"},
+		},
+		{
+			name:   "doctype is comment",
+			source: `<!DOCTYPE html><html></html>`,
+			tokens: []string{"comment:<!DOCTYPE html>", "<html", "</html"},
+		},
+		{
+			name:   "unterminated script",
+			source: `<script>var a = "<b>";`,
+			tokens: []string{"<script", `text:var a = "<b>";`},
+			errs:   []error{ErrUnterminatedRawText},
+		},
+		{
+			name:   "unterminated title",
+			source: `<title>a &amp; b`,
+			tokens: []string{"<title", "text:a & b"},
+			errs:   []error{ErrUnterminatedRawText},
+		},
+		{
+			name:   "unterminated comment",
+			source: `<p>a</p><!-- b`,
+			tokens: []string{"<p", "text:a", "</p", "comment:<!-- b"},
+			errs:   []error{ErrUnterminatedComment},
+		},
+		{
+			name:   "unterminated cdata",
+			source: `<![CDATA[a < b`,
+			tokens: []string{"text:a < b"},
+			errs:   []error{ErrUnterminatedCDATA},
+		},
+	}
+
+	for _, test := range tests {
+		readers := map[string]func() io.Reader{
+			"whole":    func() io.Reader { return strings.NewReader(test.source) },
+			"one byte": func() io.Reader { return iotest.OneByteReader(strings.NewReader(test.source)) },
+		}
+		for readerName, reader := range readers {
+			t.Run(test.name+"/"+readerName, func(t *testing.T) {
+				tokens, diagnostics := tokenize(reader())
+				if !slices.Equal(tokens, test.tokens) {
+					t.Errorf("tokens\n got: %q\nwant: %q", tokens, test.tokens)
+				}
+
+				errs := diagnosticErrors(diagnostics)
+				if len(errs) != len(test.errs) {
+					t.Fatalf("diagnostics got: %v, want: %v", errs, test.errs)
+				}
+				for idx, err := range test.errs {
+					if !errors.Is(errs[idx], err) {
+						t.Errorf("diagnostic %d got: %v, want: %v", idx, errs[idx], err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTokenizerSavedPages(t *testing.T) {
+	tests := []struct {
+		page string
+		// Raw text of the page which must be kept inside the single text token
+		texts []string
+	}{
+		{
+			page: "testdata/article.html",
+			texts: []string{
+				textToken(`Нацбанк зберіг облікову ставку & оновив прогноз інфляції — Новини`),
+				textToken(`document.write("<div class=\"cookie-banner\">Ми використовуємо cookies</div>");`),
+				textToken(`var html = '<div class="related"><a href="/economy">' + "Ще новини" + '</a></div>';`),
+				textToken(`Напишіть нам <тут>`),
+			},
+		},
+		{
+			page: "testdata/listing.html",
+			texts: []string{
+				textToken(`return document.getElementById(id) || "<div id='" + id + "'></div>";`),
+				textToken(`Новина номер 40: подія «дня» & коментарі експертів`),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.page, func(t *testing.T) {
+			page, err := os.ReadFile(test.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, diagnostics := tokenize(strings.NewReader(string(page)))
+			if len(diagnostics) > 0 {
+				t.Errorf("unexpected diagnostics: %v", diagnostics)
+			}
+
+			for _, text := range test.texts {
+				// The text is the part of the token, like the line of the script
+				prefix := textToken("")
+				found := slices.ContainsFunc(tokens, func(tok string) bool {
+					return strings.HasPrefix(tok, prefix) && strings.Contains(tok, strings.TrimPrefix(text, prefix))
+				})
+				if !found {
+					t.Errorf("text %q is not found in the single token", text)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/romashorodok/news-tracker/worker/pkg/parser/token"
 )
 
@@ -21,31 +23,96 @@ type ParserState uint8
 
 const (
 	NORMAL ParserState = iota
-	SCRIPT_CONTENT
+	// Content of the `script`, `style`, `noscript` is the text as is
+	RAW_TEXT
+	// Content of the `textarea`, `title` is the text with character references
+	RCDATA
 )
 
 type Lexeme string
 
 const (
-	SCRIPT       Lexeme = "script"
-	CLOSE_SCRIPT Lexeme = "</script>"
+	SCRIPT   Lexeme = "script"
+	STYLE    Lexeme = "style"
+	NOSCRIPT Lexeme = "noscript"
+	TEXTAREA Lexeme = "textarea"
+	TITLE    Lexeme = "title"
 )
 
+// Elements which never have the content and closing tag
+// https://html.spec.whatwg.org/multipage/syntax.html#void-elements
+var VOID_ELEMENTS = map[string]struct{}{
+	"area":   {},
+	"base":   {},
+	"br":     {},
+	"col":    {},
+	"embed":  {},
+	"hr":     {},
+	"img":    {},
+	"input":  {},
+	"keygen": {},
+	"link":   {},
+	"meta":   {},
+	"param":  {},
+	"source": {},
+	"track":  {},
+	"wbr":    {},
+}
+
+func IsVoidElement(name string) bool {
+	_, ok := VOID_ELEMENTS[strings.ToLower(name)]
+	return ok
+}
+
+// Elements which closing tag may be omitted, they are not reported as unclosed
+// https://html.spec.whatwg.org/multipage/syntax.html#optional-tags
+var OPTIONAL_END_TAG_ELEMENTS = map[string]struct{}{
+	"html":     {},
+	"head":     {},
+	"body":     {},
+	"p":        {},
+	"li":       {},
+	"dt":       {},
+	"dd":       {},
+	"option":   {},
+	"optgroup": {},
+	"colgroup": {},
+	"caption":  {},
+	"thead":    {},
+	"tbody":    {},
+	"tfoot":    {},
+	"tr":       {},
+	"td":       {},
+	"th":       {},
+	"rt":       {},
+	"rp":       {},
+}
+
 type NodeType string
 
 const (
 	CLOSE_NODE NodeType = "CLOSE_NODE"
 	OPEN_NODE  NodeType = "OPEN_NODE"
 	TEXT_NODE  NodeType = "TEXT_NODE"
+	// Root of the whole document tree, it's not an element
+	DOCUMENT_NODE NodeType = "DOCUMENT_NODE"
 )
 
+const DOCUMENT_NODE_NAME = "#document"
+
 type Node struct {
 	Name    string
 	Type    NodeType
 	Tag     token.OpenTag
 	Content string
 
-	Next *Node
+	Parent      *Node
+	FirstChild  *Node
+	LastChild   *Node
+	PrevSibling *Node
+	NextSibling *Node
+	// Depth of the node inside the selected tree. The root node has 0 depth.
+	Depth int
 }
 
 type Selector interface {
@@ -53,3 +120,41 @@ type Selector interface {
 	OnClose(Node)
 	GetPendingNode() *Node
 }
+
+// Elements which close the open `p` element
+// https://html.spec.whatwg.org/multipage/syntax.html#optional-tags
+var P_CLOSING_ELEMENTS = map[string]struct{}{
+	"address": {}, "article": {}, "aside": {}, "blockquote": {}, "details": {}, "dialog": {},
+	"div": {}, "dl": {}, "dd": {}, "dt": {}, "fieldset": {}, "figcaption": {}, "figure": {},
+	"footer": {}, "form": {}, "h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+	"header": {}, "hgroup": {}, "hr": {}, "li": {}, "main": {}, "menu": {}, "nav": {},
+	"ol": {}, "p": {}, "pre": {}, "section": {}, "table": {}, "ul": {},
+}
+
+// The open element lost its optional end tag, when the next element is opened in it.
+// Like the `<li>` closes the previous `<li>` and the `<div>` closes the `<p>`.
+func IsImpliedEndTag(open, next string) bool {
+	open, next = strings.ToLower(open), strings.ToLower(next)
+	switch open {
+	case "p":
+		_, ok := P_CLOSING_ELEMENTS[next]
+		return ok
+	case "li":
+		return next == "li"
+	case "dt", "dd":
+		return next == "dt" || next == "dd"
+	case "rt", "rp":
+		return next == "rt" || next == "rp"
+	case "option":
+		return next == "option" || next == "optgroup"
+	case "optgroup":
+		return next == "optgroup"
+	case "tr":
+		return next == "tr" || next == "thead" || next == "tbody" || next == "tfoot"
+	case "td", "th":
+		return next == "td" || next == "th" || next == "tr" || next == "thead" || next == "tbody" || next == "tfoot"
+	case "thead", "tbody":
+		return next == "tbody" || next == "tfoot"
+	}
+	return false
+}
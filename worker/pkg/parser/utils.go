@@ -4,14 +4,6 @@ import (
 	"strings"
 )
 
-func LastChildOfNode(node *Node) *Node {
-	parentNode := node
-	for parentNode.Next != nil {
-		parentNode = parentNode.Next
-	}
-	return parentNode
-}
-
 func ContainsClass(classString string, classSelectors []string) bool {
 	for _, selector := range classSelectors {
 		if strings.Contains(classString, selector) {
@@ -25,12 +17,9 @@ func ContainsClass(classString string, classSelectors []string) bool {
 // 	return strings.Repeat("  ", depth)
 // }
 //
-// func traverse(node *Node, indent int) {
-// 	if node != nil {
-// 		fmt.Printf("%s%s %s %+v\n", getIndent(indent), node.Name, node.Content, node.Tag.Attr)
-//
-// 		if node.Next != nil {
-// 			traverse(node.Next, indent+1)
-// 		}
-// 	}
+// func traverse(node *Node) {
+// 	node.Walk(func(node *Node) bool {
+// 		fmt.Printf("%s%s %s %+v\n", getIndent(node.Depth), node.Name, node.Content, node.Tag.Attr)
+// 		return true
+// 	})
 // }
@@ -0,0 +1,485 @@
+package xpath
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type nodeTest struct {
+	kind NodeTestKind
+	name string
+}
+
+type step struct {
+	axis       Axis
+	test       nodeTest
+	predicates []expr
+}
+
+// The `//` abbreviation of the `/descendant-or-self::node()/`
+var descendantOrSelfStep = step{axis: AXIS_DESCENDANT_OR_SELF, test: nodeTest{kind: TEST_NODE}}
+
+type pathExpr struct {
+	absolute bool
+	steps    []step
+}
+
+type literalExpr string
+
+type numberExpr float64
+
+type binaryExpr struct {
+	op          Operator
+	left, right expr
+}
+
+type functionExpr struct {
+	name string
+	args []expr
+}
+
+// Expr is the compiled xpath expression, it's safe for concurrent use
+type Expr struct {
+	source string
+	// Union of the location paths `//h1 | //h2`
+	paths []*pathExpr
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+type compiler struct {
+	source string
+	pos    int
+}
+
+func (c *compiler) errorf(err error, format string, args ...any) error {
+	return errors.Join(err, fmt.Errorf("at %d in %q: %s", c.pos, c.source, fmt.Sprintf(format, args...)))
+}
+
+func (c *compiler) eof() bool {
+	return c.pos >= len(c.source)
+}
+
+func (c *compiler) peek() byte {
+	if c.eof() {
+		return 0
+	}
+	return c.source[c.pos]
+}
+
+func (c *compiler) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(c.source[c.pos:], prefix)
+}
+
+func isSpace(symbol byte) bool {
+	switch symbol {
+	case ' ', '\n', '\r', '\t':
+		return true
+	}
+	return false
+}
+
+func isDigit(symbol byte) bool {
+	return '0' <= symbol && symbol <= '9'
+}
+
+func isNameSymbol(symbol byte) bool {
+	return 'a' <= symbol && symbol <= 'z' ||
+		'A' <= symbol && symbol <= 'Z' ||
+		isDigit(symbol) ||
+		symbol == '-' || symbol == '_' || symbol >= 0x80
+}
+
+func (c *compiler) skipSpaces() {
+	for !c.eof() && isSpace(c.peek()) {
+		c.pos++
+	}
+}
+
+func (c *compiler) expect(symbol byte) error {
+	c.skipSpaces()
+	if c.eof() {
+		return c.errorf(ErrUnexpectedEnd, "expected %q", symbol)
+	}
+	if c.peek() != symbol {
+		return c.errorf(ErrUnexpectedSymbol, "expected %q got %q", symbol, c.peek())
+	}
+	c.pos++
+	return nil
+}
+
+func (c *compiler) name() (string, error) {
+	start := c.pos
+	for !c.eof() && isNameSymbol(c.peek()) {
+		c.pos++
+	}
+	if c.pos == start {
+		if c.eof() {
+			return "", c.errorf(ErrUnexpectedEnd, "expected name")
+		}
+		return "", c.errorf(ErrUnexpectedSymbol, "expected name got %q", c.peek())
+	}
+	return c.source[start:c.pos], nil
+}
+
+// Look at the name and the symbol after it without moving
+func (c *compiler) peekName() (string, byte) {
+	start := c.pos
+	defer func() { c.pos = start }()
+
+	name, err := c.name()
+	if err != nil {
+		return "", 0
+	}
+	c.skipSpaces()
+	return name, c.peek()
+}
+
+func (c *compiler) literal() (expr, error) {
+	quote := c.peek()
+	c.pos++
+
+	end := strings.IndexByte(c.source[c.pos:], quote)
+	if end == -1 {
+		return nil, c.errorf(ErrUnterminatedString, "expected closing %q", quote)
+	}
+	value := c.source[c.pos : c.pos+end]
+	c.pos += end + 1
+	return literalExpr(value), nil
+}
+
+func (c *compiler) number() (expr, error) {
+	start := c.pos
+	for !c.eof() && (isDigit(c.peek()) || c.peek() == '.') {
+		c.pos++
+	}
+	value, err := strconv.ParseFloat(c.source[start:c.pos], 64)
+	if err != nil {
+		return nil, c.errorf(ErrUnexpectedSymbol, "invalid number %q", c.source[start:c.pos])
+	}
+	return numberExpr(value), nil
+}
+
+func (c *compiler) function() (expr, error) {
+	name, _ := c.name()
+	arity, ok := FUNCTIONS[name]
+	if !ok {
+		return nil, c.errorf(ErrUnsupportedFunction, "%s()", name)
+	}
+	if err := c.expect('('); err != nil {
+		return nil, err
+	}
+
+	function := &functionExpr{name: name}
+	c.skipSpaces()
+	if c.peek() == ')' {
+		c.pos++
+	} else {
+		for {
+			arg, err := c.orExpr()
+			if err != nil {
+				return nil, err
+			}
+			function.args = append(function.args, arg)
+
+			c.skipSpaces()
+			if c.peek() == ',' {
+				c.pos++
+				continue
+			}
+			if err := c.expect(')'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	if len(function.args) < arity.min || (arity.max != -1 && len(function.args) > arity.max) {
+		return nil, c.errorf(ErrInvalidArgumentCount, "%s() got %d arguments", name, len(function.args))
+	}
+	return function, nil
+}
+
+func (c *compiler) nodeTest(axis Axis) (nodeTest, error) {
+	if c.peek() == '*' {
+		c.pos++
+		return nodeTest{kind: TEST_ANY}, nil
+	}
+
+	name, err := c.name()
+	if err != nil {
+		return nodeTest{}, err
+	}
+
+	if axis != AXIS_ATTRIBUTE && (name == "text" || name == "node") {
+		start := c.pos
+		c.skipSpaces()
+		if c.peek() == '(' {
+			c.pos++
+			if err := c.expect(')'); err != nil {
+				return nodeTest{}, err
+			}
+			if name == "text" {
+				return nodeTest{kind: TEST_TEXT}, nil
+			}
+			return nodeTest{kind: TEST_NODE}, nil
+		}
+		c.pos = start
+	}
+
+	return nodeTest{kind: TEST_NAME, name: name}, nil
+}
+
+func (c *compiler) step() (step, error) {
+	var result step
+
+	switch {
+	case c.hasPrefix(".."):
+		c.pos += 2
+		result = step{axis: AXIS_PARENT, test: nodeTest{kind: TEST_NODE}}
+
+	case c.hasPrefix("."):
+		c.pos++
+		result = step{axis: AXIS_SELF, test: nodeTest{kind: TEST_NODE}}
+
+	case c.hasPrefix("@"):
+		c.pos++
+		test, err := c.nodeTest(AXIS_ATTRIBUTE)
+		if err != nil {
+			return step{}, err
+		}
+		result = step{axis: AXIS_ATTRIBUTE, test: test}
+
+	default:
+		axis := AXIS_CHILD
+		if name, next := c.peekName(); next == ':' {
+			var ok bool
+			if axis, ok = AXES[name]; !ok {
+				return step{}, c.errorf(ErrUnsupportedAxis, "%s::", name)
+			}
+			c.pos += len(name)
+			c.skipSpaces()
+			if !c.hasPrefix("::") {
+				return step{}, c.errorf(ErrUnexpectedSymbol, "expected `::` after the axis")
+			}
+			c.pos += 2
+			c.skipSpaces()
+		}
+
+		test, err := c.nodeTest(axis)
+		if err != nil {
+			return step{}, err
+		}
+		result = step{axis: axis, test: test}
+	}
+
+	for {
+		c.skipSpaces()
+		if c.peek() != '[' {
+			return result, nil
+		}
+		c.pos++
+
+		predicate, err := c.orExpr()
+		if err != nil {
+			return step{}, err
+		}
+		if err := c.expect(']'); err != nil {
+			return step{}, err
+		}
+		result.predicates = append(result.predicates, predicate)
+	}
+}
+
+func isStepStart(symbol byte) bool {
+	return symbol == '.' || symbol == '@' || symbol == '*' || isNameSymbol(symbol)
+}
+
+func (c *compiler) path() (*pathExpr, error) {
+	path := &pathExpr{}
+
+	switch {
+	case c.hasPrefix("//"):
+		c.pos += 2
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelfStep)
+
+	case c.hasPrefix("/"):
+		c.pos++
+		path.absolute = true
+		// The `/` alone select the document
+		c.skipSpaces()
+		if !isStepStart(c.peek()) {
+			return path, nil
+		}
+	}
+
+	for {
+		c.skipSpaces()
+		if c.eof() {
+			return nil, c.errorf(ErrUnexpectedEnd, "expected location step")
+		}
+		if !isStepStart(c.peek()) {
+			return nil, c.errorf(ErrUnexpectedSymbol, "expected location step got %q", c.peek())
+		}
+
+		step, err := c.step()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+
+		c.skipSpaces()
+		switch {
+		case c.hasPrefix("//"):
+			c.pos += 2
+			path.steps = append(path.steps, descendantOrSelfStep)
+		case c.hasPrefix("/"):
+			c.pos++
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (c *compiler) primaryExpr() (expr, error) {
+	c.skipSpaces()
+	if c.eof() {
+		return nil, c.errorf(ErrUnexpectedEnd, "expected expression")
+	}
+
+	symbol := c.peek()
+	switch {
+	case symbol == '(':
+		c.pos++
+		inner, err := c.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := c.expect(')'); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case symbol == '\'' || symbol == '"':
+		return c.literal()
+
+	case isDigit(symbol) || (symbol == '.' && c.pos+1 < len(c.source) && isDigit(c.source[c.pos+1])):
+		return c.number()
+	}
+
+	// The name followed by the `(` is the function call, except node type tests
+	if name, next := c.peekName(); next == '(' && name != "text" && name != "node" {
+		return c.function()
+	}
+
+	return c.path()
+}
+
+// Operator at the position. Names `and`, `or` are operators only when they are the whole word.
+func (c *compiler) operator(operators ...Operator) (Operator, bool) {
+	c.skipSpaces()
+	for _, op := range operators {
+		if !c.hasPrefix(string(op)) {
+			continue
+		}
+		end := c.pos + len(op)
+		if isNameSymbol(op[0]) && end < len(c.source) && isNameSymbol(c.source[end]) {
+			continue
+		}
+		c.pos = end
+		return op, true
+	}
+	return "", false
+}
+
+// Parse the left associative chain of the binary operators
+func (c *compiler) binaryChain(operand func() (expr, error), operators ...Operator) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := c.operator(operators...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (c *compiler) relationalExpr() (expr, error) {
+	// Longer operators go first
+	return c.binaryChain(c.primaryExpr, OP_LE, OP_GE, OP_LT, OP_GT)
+}
+
+func (c *compiler) equalityExpr() (expr, error) {
+	return c.binaryChain(c.relationalExpr, OP_NE, OP_EQ)
+}
+
+func (c *compiler) andExpr() (expr, error) {
+	return c.binaryChain(c.equalityExpr, OP_AND)
+}
+
+func (c *compiler) orExpr() (expr, error) {
+	return c.binaryChain(c.andExpr, OP_OR)
+}
+
+// Compile the xpath 1.0 subset.
+//
+// Supported:
+// location paths `/html/body`, `//div`, `p`, `.`, `..`, `@href`, union `//h1 | //h2`,
+// axes `child::`, `descendant::`, `descendant-or-self::`, `parent::`, `following-sibling::`, `self::`, `attribute::`,
+// node tests `div`, `*`, `text()`, `node()`,
+// predicates `[2]`, `[position() > 1]`, `[@data-type='article']`, `[contains(@class, 'item') and not(@hidden)]`,
+// operators `or`, `and`, `=`, `!=`, `<`, `<=`, `>`, `>=`
+// and functions position, last, count, name, string, string-length, normalize-space, number, concat, contains, starts-with, not, true, false.
+func Compile(source string) (*Expr, error) {
+	c := &compiler{source: source}
+	compiled := &Expr{source: source}
+
+	c.skipSpaces()
+	if c.eof() {
+		return nil, ErrEmptyExpression
+	}
+
+	for {
+		c.skipSpaces()
+		start := c.pos
+		compiledExpr, err := c.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		path, ok := compiledExpr.(*pathExpr)
+		if !ok {
+			c.pos = start
+			return nil, c.errorf(ErrNotLocationPath, "only nodes can be selected")
+		}
+		compiled.paths = append(compiled.paths, path)
+
+		c.skipSpaces()
+		if c.eof() {
+			return compiled, nil
+		}
+		if c.peek() != '|' {
+			return nil, c.errorf(ErrUnexpectedSymbol, "expected `|` got %q", c.peek())
+		}
+		c.pos++
+	}
+}
+
+func MustCompile(source string) *Expr {
+	compiled, err := Compile(source)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
@@ -0,0 +1,508 @@
+package xpath
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/pkg/parser"
+)
+
+// Node or the attribute of the node. The parser keeps attributes in the tag, so they are not nodes.
+type item struct {
+	node *parser.Node
+	// Not empty when the item is the attribute of the node
+	attr string
+}
+
+func (i item) isAttr() bool {
+	return i.attr != ""
+}
+
+func (i item) stringValue() string {
+	if i.isAttr() {
+		return i.node.Attr(i.attr)
+	}
+	return i.node.Text()
+}
+
+type valueKind uint8
+
+const (
+	kindNodeSet valueKind = iota
+	kindString
+	kindNumber
+	kindBoolean
+)
+
+type value struct {
+	kind    valueKind
+	items   []item
+	str     string
+	num     float64
+	boolean bool
+}
+
+func stringValue(str string) value {
+	return value{kind: kindString, str: str}
+}
+
+func numberValue(num float64) value {
+	return value{kind: kindNumber, num: num}
+}
+
+func booleanValue(boolean bool) value {
+	return value{kind: kindBoolean, boolean: boolean}
+}
+
+func toNumber(str string) float64 {
+	num, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return num
+}
+
+func (v value) toString() string {
+	switch v.kind {
+	case kindNodeSet:
+		if len(v.items) == 0 {
+			return ""
+		}
+		return v.items[0].stringValue()
+	case kindNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case kindBoolean:
+		return strconv.FormatBool(v.boolean)
+	}
+	return v.str
+}
+
+func (v value) toNumber() float64 {
+	switch v.kind {
+	case kindNumber:
+		return v.num
+	case kindBoolean:
+		if v.boolean {
+			return 1
+		}
+		return 0
+	}
+	return toNumber(v.toString())
+}
+
+func (v value) toBoolean() bool {
+	switch v.kind {
+	case kindNodeSet:
+		return len(v.items) > 0
+	case kindNumber:
+		return v.num != 0 && !math.IsNaN(v.num)
+	case kindString:
+		return v.str != ""
+	}
+	return v.boolean
+}
+
+// Position of the nodes in the document. It's built on first use, most of paths never need it.
+type documentOrder struct {
+	indexes map[*parser.Node]int
+}
+
+func (o *documentOrder) index(node *parser.Node) int {
+	if o.indexes == nil {
+		o.indexes = make(map[*parser.Node]int)
+		root := node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		root.Walk(func(node *parser.Node) bool {
+			o.indexes[node] = len(o.indexes)
+			return true
+		})
+	}
+	return o.indexes[node]
+}
+
+// Attributes go after their node and before its children
+func (o *documentOrder) sort(items []item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		left, right := o.index(items[i].node), o.index(items[j].node)
+		if left != right {
+			return left < right
+		}
+		return items[i].attr < items[j].attr
+	})
+}
+
+type context struct {
+	item     item
+	position int
+	size     int
+	order    *documentOrder
+}
+
+type expr interface {
+	eval(ctx *context) value
+}
+
+func (e literalExpr) eval(*context) value {
+	return stringValue(string(e))
+}
+
+func (e numberExpr) eval(*context) value {
+	return numberValue(float64(e))
+}
+
+func (t nodeTest) matches(axis Axis, candidate item) bool {
+	switch t.kind {
+	case TEST_NODE:
+		return true
+	case TEST_TEXT:
+		return !candidate.isAttr() && candidate.node.Type == parser.TEXT_NODE
+	}
+
+	// The name tests select the attributes on the attribute axis and the elements on the others
+	if axis == AXIS_ATTRIBUTE {
+		return candidate.isAttr() && (t.kind == TEST_ANY || strings.EqualFold(t.name, candidate.attr))
+	}
+	return !candidate.isAttr() && candidate.node.IsElement() &&
+		(t.kind == TEST_ANY || strings.EqualFold(t.name, candidate.node.Name))
+}
+
+// Items of the axis in the axis order
+func axisItems(axis Axis, context item) []item {
+	var items []item
+
+	switch axis {
+	case AXIS_SELF:
+		items = append(items, context)
+
+	case AXIS_PARENT:
+		if context.isAttr() {
+			items = append(items, item{node: context.node})
+		} else if context.node.Parent != nil {
+			items = append(items, item{node: context.node.Parent})
+		}
+
+	case AXIS_ATTRIBUTE:
+		if context.isAttr() {
+			break
+		}
+		keys := make([]string, 0, len(context.node.Tag.Attr))
+		for key := range context.node.Tag.Attr {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			items = append(items, item{node: context.node, attr: key})
+		}
+
+	case AXIS_CHILD:
+		if context.isAttr() {
+			break
+		}
+		for child := context.node.FirstChild; child != nil; child = child.NextSibling {
+			items = append(items, item{node: child})
+		}
+
+	case AXIS_DESCENDANT, AXIS_DESCENDANT_OR_SELF:
+		if context.isAttr() {
+			if axis == AXIS_DESCENDANT_OR_SELF {
+				items = append(items, context)
+			}
+			break
+		}
+		context.node.Walk(func(node *parser.Node) bool {
+			if node != context.node || axis == AXIS_DESCENDANT_OR_SELF {
+				items = append(items, item{node: node})
+			}
+			return true
+		})
+
+	case AXIS_FOLLOWING_SIBLING:
+		if context.isAttr() {
+			break
+		}
+		for sibling := context.node.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+			items = append(items, item{node: sibling})
+		}
+	}
+
+	return items
+}
+
+// Keep the items which satisfy the predicate. The number predicate is the position.
+func filter(items []item, predicate expr, order *documentOrder) []item {
+	var result []item
+	for idx, candidate := range items {
+		ctx := &context{item: candidate, position: idx + 1, size: len(items), order: order}
+		value := predicate.eval(ctx)
+
+		if value.kind == kindNumber {
+			if value.num == float64(ctx.position) {
+				result = append(result, candidate)
+			}
+			continue
+		}
+		if value.toBoolean() {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+func (s step) apply(contexts []item, order *documentOrder) []item {
+	var result []item
+	seen := make(map[item]struct{})
+
+	for _, context := range contexts {
+		var candidates []item
+		for _, candidate := range axisItems(s.axis, context) {
+			if s.test.matches(s.axis, candidate) {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		// Positions are counted for each context separately
+		for _, predicate := range s.predicates {
+			candidates = filter(candidates, predicate, order)
+		}
+
+		for _, candidate := range candidates {
+			if _, ok := seen[candidate]; ok {
+				continue
+			}
+			seen[candidate] = struct{}{}
+			result = append(result, candidate)
+		}
+	}
+
+	// Results of the different contexts may interleave
+	if len(contexts) > 1 {
+		order.sort(result)
+	}
+	return result
+}
+
+func (p *pathExpr) eval(ctx *context) value {
+	items := []item{ctx.item}
+	if p.absolute {
+		root := ctx.item.node
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		items = []item{{node: root}}
+	}
+
+	for _, step := range p.steps {
+		if len(items) == 0 {
+			break
+		}
+		items = step.apply(items, ctx.order)
+	}
+	return value{kind: kindNodeSet, items: items}
+}
+
+func compareNumbers(op Operator, left, right float64) bool {
+	switch op {
+	case OP_EQ:
+		return left == right
+	case OP_NE:
+		return left != right
+	case OP_LT:
+		return left < right
+	case OP_LE:
+		return left <= right
+	case OP_GT:
+		return left > right
+	case OP_GE:
+		return left >= right
+	}
+	return false
+}
+
+// Compare the values which are not node sets
+func compareAtoms(op Operator, left, right value) bool {
+	if op != OP_EQ && op != OP_NE {
+		return compareNumbers(op, left.toNumber(), right.toNumber())
+	}
+
+	var equal bool
+	switch {
+	case left.kind == kindBoolean || right.kind == kindBoolean:
+		equal = left.toBoolean() == right.toBoolean()
+	case left.kind == kindNumber || right.kind == kindNumber:
+		equal = left.toNumber() == right.toNumber()
+	default:
+		equal = left.toString() == right.toString()
+	}
+
+	if op == OP_EQ {
+		return equal
+	}
+	return !equal
+}
+
+// The node set is equal to the value when any of its items is equal to it
+func compare(op Operator, left, right value) bool {
+	switch {
+	case left.kind == kindNodeSet && right.kind == kindNodeSet:
+		for _, leftItem := range left.items {
+			for _, rightItem := range right.items {
+				if compareAtoms(op, stringValue(leftItem.stringValue()), stringValue(rightItem.stringValue())) {
+					return true
+				}
+			}
+		}
+		return false
+
+	case left.kind == kindNodeSet:
+		if right.kind == kindBoolean {
+			return compareAtoms(op, booleanValue(left.toBoolean()), right)
+		}
+		for _, leftItem := range left.items {
+			if compareAtoms(op, stringValue(leftItem.stringValue()), right) {
+				return true
+			}
+		}
+		return false
+
+	case right.kind == kindNodeSet:
+		if left.kind == kindBoolean {
+			return compareAtoms(op, left, booleanValue(right.toBoolean()))
+		}
+		for _, rightItem := range right.items {
+			if compareAtoms(op, left, stringValue(rightItem.stringValue())) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return compareAtoms(op, left, right)
+}
+
+func (e *binaryExpr) eval(ctx *context) value {
+	switch e.op {
+	case OP_OR:
+		return booleanValue(e.left.eval(ctx).toBoolean() || e.right.eval(ctx).toBoolean())
+	case OP_AND:
+		return booleanValue(e.left.eval(ctx).toBoolean() && e.right.eval(ctx).toBoolean())
+	}
+	return booleanValue(compare(e.op, e.left.eval(ctx), e.right.eval(ctx)))
+}
+
+// String of the first argument or the context item
+func (e *functionExpr) stringArg(ctx *context) string {
+	if len(e.args) == 0 {
+		return ctx.item.stringValue()
+	}
+	return e.args[0].eval(ctx).toString()
+}
+
+func (e *functionExpr) eval(ctx *context) value {
+	switch e.name {
+	case "position":
+		return numberValue(float64(ctx.position))
+	case "last":
+		return numberValue(float64(ctx.size))
+	case "count":
+		return numberValue(float64(len(e.args[0].eval(ctx).items)))
+	case "name":
+		target := ctx.item
+		if len(e.args) > 0 {
+			items := e.args[0].eval(ctx).items
+			if len(items) == 0 {
+				return stringValue("")
+			}
+			target = items[0]
+		}
+		if target.isAttr() {
+			return stringValue(target.attr)
+		}
+		if target.node.IsElement() {
+			return stringValue(target.node.Name)
+		}
+		return stringValue("")
+	case "string":
+		return stringValue(e.stringArg(ctx))
+	case "string-length":
+		return numberValue(float64(len([]rune(e.stringArg(ctx)))))
+	case "normalize-space":
+		return stringValue(strings.Join(strings.Fields(e.stringArg(ctx)), " "))
+	case "number":
+		if len(e.args) == 0 {
+			return numberValue(toNumber(ctx.item.stringValue()))
+		}
+		return numberValue(e.args[0].eval(ctx).toNumber())
+	case "concat":
+		var result strings.Builder
+		for _, arg := range e.args {
+			result.WriteString(arg.eval(ctx).toString())
+		}
+		return stringValue(result.String())
+	case "contains":
+		return booleanValue(strings.Contains(e.args[0].eval(ctx).toString(), e.args[1].eval(ctx).toString()))
+	case "starts-with":
+		return booleanValue(strings.HasPrefix(e.args[0].eval(ctx).toString(), e.args[1].eval(ctx).toString()))
+	case "not":
+		return booleanValue(!e.args[0].eval(ctx).toBoolean())
+	case "true":
+		return booleanValue(true)
+	case "false":
+		return booleanValue(false)
+	}
+	return booleanValue(false)
+}
+
+func (e *Expr) evaluate(node *parser.Node) []item {
+	order := &documentOrder{}
+	ctx := &context{item: item{node: node}, position: 1, size: 1, order: order}
+
+	var result []item
+	for _, path := range e.paths {
+		result = append(result, path.eval(ctx).items...)
+	}
+	// The paths of the union may select the same items
+	if len(e.paths) > 1 {
+		order.sort(result)
+		result = slices.Compact(result)
+	}
+	return result
+}
+
+// Select the nodes by the expression in document order.
+// The relative paths start from the node, the absolute ones from the root of its tree.
+// When the expression select attributes, their elements are returned.
+func (e *Expr) Select(node *parser.Node) []*parser.Node {
+	var nodes []*parser.Node
+	for _, selected := range e.evaluate(node) {
+		// Several attributes of the same element go one after another
+		if len(nodes) > 0 && nodes[len(nodes)-1] == selected.node {
+			continue
+		}
+		nodes = append(nodes, selected.node)
+	}
+	return nodes
+}
+
+// First node selected by the expression or nil
+func (e *Expr) SelectFirst(node *parser.Node) *parser.Node {
+	nodes := e.Select(node)
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+// String values of the selected nodes or attributes in document order.
+// The string value of the element is its text.
+func (e *Expr) Strings(node *parser.Node) []string {
+	var values []string
+	for _, selected := range e.evaluate(node) {
+		values = append(values, selected.stringValue())
+	}
+	return values
+}
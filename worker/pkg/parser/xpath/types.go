@@ -0,0 +1,84 @@
+package xpath
+
+import "errors"
+
+type Axis string
+
+const (
+	AXIS_CHILD              Axis = "child"
+	AXIS_DESCENDANT         Axis = "descendant"
+	AXIS_DESCENDANT_OR_SELF Axis = "descendant-or-self"
+	AXIS_PARENT             Axis = "parent"
+	AXIS_FOLLOWING_SIBLING  Axis = "following-sibling"
+	AXIS_SELF               Axis = "self"
+	AXIS_ATTRIBUTE          Axis = "attribute"
+)
+
+var AXES = map[string]Axis{
+	string(AXIS_CHILD):              AXIS_CHILD,
+	string(AXIS_DESCENDANT):         AXIS_DESCENDANT,
+	string(AXIS_DESCENDANT_OR_SELF): AXIS_DESCENDANT_OR_SELF,
+	string(AXIS_PARENT):             AXIS_PARENT,
+	string(AXIS_FOLLOWING_SIBLING):  AXIS_FOLLOWING_SIBLING,
+	string(AXIS_SELF):               AXIS_SELF,
+	string(AXIS_ATTRIBUTE):          AXIS_ATTRIBUTE,
+}
+
+type NodeTestKind uint8
+
+const (
+	// Element or attribute with the name
+	TEST_NAME NodeTestKind = iota
+	// `*` any element or attribute
+	TEST_ANY
+	// `text()`
+	TEST_TEXT
+	// `node()` any node
+	TEST_NODE
+)
+
+type Operator string
+
+const (
+	OP_OR  Operator = "or"
+	OP_AND Operator = "and"
+	OP_EQ  Operator = "="
+	OP_NE  Operator = "!="
+	OP_LT  Operator = "<"
+	OP_LE  Operator = "<="
+	OP_GT  Operator = ">"
+	OP_GE  Operator = ">="
+)
+
+// Number of the function arguments. The -1 max is unlimited.
+type functionArity struct {
+	min, max int
+}
+
+var FUNCTIONS = map[string]functionArity{
+	"position":        {0, 0},
+	"last":            {0, 0},
+	"count":           {1, 1},
+	"name":            {0, 1},
+	"string":          {0, 1},
+	"string-length":   {0, 1},
+	"normalize-space": {0, 1},
+	"number":          {0, 1},
+	"concat":          {2, -1},
+	"contains":        {2, 2},
+	"starts-with":     {2, 2},
+	"not":             {1, 1},
+	"true":            {0, 0},
+	"false":           {0, 0},
+}
+
+var (
+	ErrEmptyExpression      = errors.New("empty expression")
+	ErrUnexpectedEnd        = errors.New("unexpected end of expression")
+	ErrUnexpectedSymbol     = errors.New("unexpected symbol")
+	ErrUnsupportedAxis      = errors.New("unsupported axis")
+	ErrUnsupportedFunction  = errors.New("unsupported function")
+	ErrInvalidArgumentCount = errors.New("invalid arguments count")
+	ErrUnterminatedString   = errors.New("unterminated string")
+	ErrNotLocationPath      = errors.New("expression must be the location path")
+)
@@ -0,0 +1,205 @@
+package robots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Agent of the group which is used when no group names the crawler
+const ANY_AGENT = "*"
+
+// Allow or Disallow line of the group
+type rule struct {
+	allow bool
+	// Path prefix with the `*` wildcard and the `$` end anchor
+	pattern string
+}
+
+// Group of the rules which is shared by the user-agent lines above them
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+// Robots is the parsed robots.txt of the site
+// https://www.rfc-editor.org/rfc/rfc9309
+type Robots struct {
+	// Sitemap urls in the order of the file
+	Sitemaps []string
+	groups   []*group
+}
+
+// Split the `Key: value # comment` line. The key is lowercased.
+func parseLine(line string) (string, string, bool) {
+	if idx := strings.IndexByte(line, '#'); idx != -1 {
+		line = line[:idx]
+	}
+	key, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), true
+}
+
+// Octets outside of the us-ascii are percent-encoded like in the url path
+func encodePattern(pattern string) string {
+	var encoded strings.Builder
+	for idx := 0; idx < len(pattern); idx++ {
+		if c := pattern[idx]; c >= 0x80 {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		} else {
+			encoded.WriteByte(c)
+		}
+	}
+	return encoded.String()
+}
+
+func Parse(source io.Reader) (*Robots, error) {
+	robots := &Robots{}
+
+	var current *group
+	// The consecutive user-agent lines share the group, the user-agent after the rules starts the new one
+	var agentLines bool
+
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		key, value, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "sitemap":
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+
+		case "user-agent":
+			if !agentLines {
+				current = &group{}
+				robots.groups = append(robots.groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			agentLines = true
+
+		case "allow", "disallow":
+			agentLines = false
+			// The rules before the first user-agent and the empty rules match nothing
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, rule{allow: key == "allow", pattern: encodePattern(value)})
+
+		case "crawl-delay":
+			agentLines = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return robots, nil
+}
+
+// Product token of the User-Agent header, like `news-tracker-worker` of `news-tracker-worker/1.0 (+https://...)`
+func productToken(userAgent string) string {
+	if idx := strings.IndexAny(userAgent, "/ "); idx != -1 {
+		userAgent = userAgent[:idx]
+	}
+	return strings.ToLower(userAgent)
+}
+
+// Groups which name the crawler, or the `*` groups when none of them does
+func (r *Robots) agentGroups(userAgent string) []*group {
+	token := productToken(userAgent)
+
+	var named, anyAgent []*group
+	for _, g := range r.groups {
+		for _, agent := range g.agents {
+			if agent == token {
+				named = append(named, g)
+				break
+			}
+			if agent == ANY_AGENT {
+				anyAgent = append(anyAgent, g)
+				break
+			}
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	return anyAgent
+}
+
+// Match the path by the pattern with the `*` wildcard and the `$` end anchor
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || path == ""
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(path, part)
+		if idx == -1 {
+			return false
+		}
+		path = path[idx+len(part):]
+	}
+
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path, last)
+}
+
+// Check the path with the query of the url, like `/news/1?page=2`.
+// The longest matching rule wins, the allow rule wins the tie. The path without matching rules is allowed.
+func (r *Robots) Allowed(userAgent, path string) bool {
+	allowed := true
+	longest := -1
+	for _, g := range r.agentGroups(userAgent) {
+		for _, rule := range g.rules {
+			if len(rule.pattern) < longest || !matchPattern(rule.pattern, path) {
+				continue
+			}
+			if len(rule.pattern) > longest {
+				allowed = rule.allow
+			} else {
+				allowed = allowed || rule.allow
+			}
+			longest = len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// Delay between the requests of the crawler, zero when the site has no Crawl-delay for it
+func (r *Robots) CrawlDelay(userAgent string) time.Duration {
+	var delay time.Duration
+	for _, g := range r.agentGroups(userAgent) {
+		delay = max(delay, g.crawlDelay)
+	}
+	return delay
+}
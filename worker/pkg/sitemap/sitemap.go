@@ -0,0 +1,164 @@
+package sitemap
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/romashorodok/news-tracker/pkg/dateutils"
+)
+
+var ErrInvalidSitemap = errors.New("invalid sitemap")
+
+// Google News extension of the url
+// https://developers.google.com/search/docs/crawling-indexing/sitemaps/news-sitemap
+type News struct {
+	PublicationName string
+	Language        string
+	PublicationDate time.Time
+	Title           string
+	Keywords        []string
+}
+
+type URL struct {
+	Loc     string
+	LastMod time.Time
+	// Present only in the news sitemaps
+	News *News
+	// Image extension `<image:image><image:loc>`
+	Images []string
+}
+
+// The publication date of the news or the last modification date. Zero when both are unknown.
+func (u URL) Date() time.Time {
+	if u.News != nil && !u.News.PublicationDate.IsZero() {
+		return u.News.PublicationDate
+	}
+	return u.LastMod
+}
+
+// Entry is the nested sitemap of the sitemap index
+type Entry struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// Sitemap is the `<urlset>` or the `<sitemapindex>`
+// https://www.sitemaps.org/protocol.html
+type Sitemap struct {
+	URLs     []URL
+	Sitemaps []Entry
+}
+
+func (s *Sitemap) IsIndex() bool {
+	return len(s.Sitemaps) > 0
+}
+
+type xmlNews struct {
+	Publication struct {
+		Name     string `xml:"name"`
+		Language string `xml:"language"`
+	} `xml:"publication"`
+	PublicationDate string `xml:"publication_date"`
+	Title           string `xml:"title"`
+	Keywords        string `xml:"keywords"`
+}
+
+func (x *xmlNews) news() *News {
+	if x == nil {
+		return nil
+	}
+
+	news := &News{
+		PublicationName: strings.TrimSpace(x.Publication.Name),
+		Language:        strings.TrimSpace(x.Publication.Language),
+		Title:           strings.TrimSpace(x.Title),
+	}
+	news.PublicationDate, _ = dateutils.ParseISO8601(x.PublicationDate)
+	for _, keyword := range strings.Split(x.Keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			news.Keywords = append(news.Keywords, keyword)
+		}
+	}
+	return news
+}
+
+type xmlURL struct {
+	Loc     string   `xml:"loc"`
+	LastMod string   `xml:"lastmod"`
+	News    *xmlNews `xml:"news"`
+	Images  []string `xml:"image>loc"`
+}
+
+type xmlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// The root is the `<urlset>` or the `<sitemapindex>`, both are decoded by the same document
+type xmlDocument struct {
+	URLs     []xmlURL   `xml:"url"`
+	Sitemaps []xmlEntry `xml:"sitemap"`
+}
+
+// The `.xml.gz` sitemaps are compressed files, not the compressed http responses
+func decompress(source io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(source)
+	magic, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// Parse the sitemap or the sitemap index, it may be compressed by the gzip
+func Parse(source io.Reader) (*Sitemap, error) {
+	source, err := decompress(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSitemap, err)
+	}
+
+	decoder := xml.NewDecoder(source)
+	// The source is already utf-8, the declared encoding is the encoding of the original document
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var document xmlDocument
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSitemap, err)
+	}
+
+	sitemap := &Sitemap{}
+	for _, entry := range document.Sitemaps {
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			lastMod, _ := dateutils.ParseISO8601(entry.LastMod)
+			sitemap.Sitemaps = append(sitemap.Sitemaps, Entry{Loc: loc, LastMod: lastMod})
+		}
+	}
+	for _, url := range document.URLs {
+		loc := strings.TrimSpace(url.Loc)
+		if loc == "" {
+			continue
+		}
+		lastMod, _ := dateutils.ParseISO8601(url.LastMod)
+
+		var images []string
+		for _, image := range url.Images {
+			if image = strings.TrimSpace(image); image != "" {
+				images = append(images, image)
+			}
+		}
+
+		sitemap.URLs = append(sitemap.URLs, URL{Loc: loc, LastMod: lastMod, News: url.News.news(), Images: images})
+	}
+	return sitemap, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/romashorodok/news-tracker/worker/internal/prebuiltemplate"
+)
+
+const TEST_TEMPLATE_COMMAND = "test-template"
+
+var ErrTemplatesFailed = errors.New("unable extract the article of the templates")
+
+// Print the article of each template without connecting to the nats.
+// The ErrTemplatesFailed is returned after all templates when some of them are failed.
+//
+// Example: worker test-template -template ./templates/example.com.yaml -article-file ./article.html
+func testTemplate(args []string) error {
+	var templates prebuiltemplate.ConfigFlag
+	var options prebuiltemplate.DryRunOptions
+	var cacheDir string
+
+	flags := flag.NewFlagSet(TEST_TEMPLATE_COMMAND, flag.ExitOnError)
+	flags.Var(&templates, "template", "The json template or the path to the json, yaml file or directory of them")
+	flags.StringVar(&options.FeedFile, "feed-file", "", "Read the saved news feed page instead of the news_feed_url")
+	flags.StringVar(&options.ArticleFile, "article-file", "", "Read the saved article page instead of the remote one")
+	flags.StringVar(&options.ArticleURL, "article-url", "", "Extract the article page instead of the first article of the news feed")
+	flags.StringVar(&cacheDir, "cache-dir", "", "Keep the remote pages in the directory, so the next runs don't request them again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(templates) == 0 {
+		return errors.New("enter the template by `-template` flag")
+	}
+
+	var failed int
+	for _, config := range templates {
+		fmt.Printf("Template %s\n", config.NewsFeedURL)
+		if cacheDir != "" {
+			config.HTTP.CacheDir = cacheDir
+		}
+
+		result, err := prebuiltemplate.DryRun(config, options)
+		if err != nil {
+			fmt.Printf("Unable extract the article. Err: %s\n\n", err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("Found %d articles on the news feed\n", len(result.ArticleURLs))
+		if result.ArticleURL != "" {
+			fmt.Printf("Article page %s\n", result.ArticleURL)
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result.Article); err != nil {
+			return err
+		}
+
+		if len(result.EmptyFields) > 0 {
+			fmt.Printf("Empty fields: %s\n", strings.Join(result.EmptyFields, ", "))
+		}
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w %d of %d", ErrTemplatesFailed, failed, len(templates))
+	}
+	return nil
+}